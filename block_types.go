@@ -0,0 +1,206 @@
+package hclconfig
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/creasty/defaults"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/dynblock"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// RegisterBlockType registers a custom top level block i.e. provider "docker" {},
+// the block is decoded into the Go type of the block and is referenced using
+// the keyword of the block followed by its labels i.e. provider.docker.version.
+// An error is returned when the keyword is already used by another block or
+// resource type.
+func (p *Parser) RegisterBlockType(bt resources.BlockType) error {
+	if _, ok := p.blockTypes[bt.Name]; ok || bt.Name == typeMoved {
+		return fmt.Errorf(`block type "%s" has already been registered`, bt.Name)
+	}
+
+	if _, ok := p.registeredTypes[bt.Name]; ok {
+		return fmt.Errorf(`unable to register block type "%s", a resource with the same type has already been registered`, bt.Name)
+	}
+
+	if err := p.blockTypes.Register(bt); err != nil {
+		return err
+	}
+
+	p.registeredTypes[bt.Name] = bt.Resource
+
+	return nil
+}
+
+// customBlockType returns the block registered with RegisterBlockType for the
+// given keyword, false is returned for the blocks defined by hclconfig
+func (p *Parser) customBlockType(name string) (resources.BlockType, bool) {
+	if isBuiltinBlock(name) {
+		return resources.BlockType{}, false
+	}
+
+	bt, ok := p.blockTypes[name]
+
+	return bt, ok
+}
+
+// blockKeywords returns the keywords of all the top level blocks that the
+// parser accepts
+func (p *Parser) blockKeywords() []string {
+	keywords := []string{typeMoved}
+	for _, bt := range p.blockTypes.Sorted() {
+		keywords = append(keywords, bt.Name)
+	}
+
+	slices.Sort(keywords)
+
+	return keywords
+}
+
+// isBuiltinBlock returns true when the block is defined by hclconfig
+func isBuiltinBlock(name string) bool {
+	switch name {
+	case types.TypeResource, types.TypeData, resources.TypeModule, resources.TypeOutput, resources.TypeLocal, resources.TypeCheck, resources.TypeVariable:
+		return true
+	}
+
+	return false
+}
+
+// newConfig creates a config that uses the blocks registered with the parser to
+// parse references to resources
+func (p *Parser) newConfig() *Config {
+	c := NewConfig()
+	c.blockTypes = p.blockTypes
+
+	return c
+}
+
+// inGraph returns true when the resource is processed when the graph is walked,
+// variables and blocks registered with DAG set to false are not
+func (c *Config) inGraph(r types.Resource) bool {
+	if r.Metadata().Data {
+		return true
+	}
+
+	if bt, ok := c.blockTypes[r.Metadata().Type]; ok {
+		return bt.DAG
+	}
+
+	return true
+}
+
+// createBlockResource creates the resource for a custom block, the name of the
+// resource is the labels of the block joined with a .
+func (p *Parser) createBlockResource(file string, b *hcl.Block, bt resources.BlockType) (types.Resource, error) {
+	if len(b.Labels) != len(bt.Labels) {
+		labels := []string{}
+		for _, l := range bt.Labels {
+			labels = append(labels, fmt.Sprintf(`"%s"`, l))
+		}
+
+		de := &errors.ParserError{}
+		de.Line = b.TypeRange.Start.Line
+		de.Column = b.TypeRange.Start.Column
+		de.Filename = file
+		de.Level = errors.ParserErrorLevelError
+		de.Message = fmt.Sprintf(`invalid formatting for '%s' stanza, the block should have the labels %s, i.e. '%s %s {}'`, b.Type, strings.Join(bt.Labels, ", "), b.Type, strings.Join(labels, " "))
+
+		return nil, de
+	}
+
+	for _, l := range b.Labels {
+		if err := validateResourceName(l); err != nil {
+			de := &errors.ParserError{}
+			de.Line = b.TypeRange.Start.Line
+			de.Column = b.TypeRange.Start.Column
+			de.Filename = file
+			de.Level = errors.ParserErrorLevelError
+			de.Message = err.Error()
+
+			return nil, de
+		}
+	}
+
+	rt, err := p.registeredTypes.CreateResource(b.Type, strings.Join(b.Labels, "."))
+	if err != nil {
+		de := &errors.ParserError{}
+		de.Line = b.TypeRange.Start.Line
+		de.Column = b.TypeRange.Start.Column
+		de.Filename = file
+		de.Level = errors.ParserErrorLevelError
+		de.Message = fmt.Sprintf("unable to create resource '%s' %s", b.Type, err)
+
+		return nil, de
+	}
+
+	return rt, nil
+}
+
+// decodeStaticResource decodes a resource that is not processed in the graph,
+// the resource can only reference variables. Once decoded the resource is
+// processed and its value is set in the context so that other resources can
+// reference it.
+func decodeStaticResource(ctx *hcl.EvalContext, c *Config, file string, b *hcl.Block, r types.Resource) error {
+	if len(r.Metadata().Links) > 0 {
+		de := &errors.ParserError{}
+		de.Line = b.TypeRange.Start.Line
+		de.Column = b.TypeRange.Start.Column
+		de.Filename = file
+		de.Level = errors.ParserErrorLevelError
+		de.Message = fmt.Sprintf(`'%s' blocks are decoded when the configuration is parsed and can only reference variables, found reference to "%s"`, b.Type, r.Metadata().Links[0])
+
+		return de
+	}
+
+	defaults.Set(resourceValue(r))
+
+	sensitive := []string{}
+	body := newUnmarkBody(dynblock.Expand(b.Body, ctx), func(path string, val cty.Value) {
+		sensitive = append(sensitive, path)
+		c.addSensitiveValues(val)
+	})
+
+	if err := validateSchemaBody(r, body); err != nil {
+		return err
+	}
+
+	if diags := gohcl.DecodeBody(body, ctx, resourceValue(r)); diags.HasErrors() {
+		de := &errors.ParserError{}
+		de.Line = b.TypeRange.Start.Line
+		de.Column = b.TypeRange.Start.Column
+		de.Filename = file
+		de.Level = errors.ParserErrorLevelError
+		de.Message = fmt.Sprintf("unable to decode body, %s", diags.Error())
+
+		return de
+	}
+
+	slices.Sort(sensitive)
+	r.Metadata().Sensitive = slices.Compact(sensitive)
+	r.Metadata().Checksum.Parsed = generateChecksum(r)
+
+	if pr, ok := r.(types.Processable); ok {
+		if err := pr.Process(); err != nil {
+			return createParserError(r, err.Error())
+		}
+	}
+
+	val, err := resourceToCtyValue(r)
+	if err != nil {
+		return createParserError(r, fmt.Sprintf(`unable to convert resource to context variable: %s`, err))
+	}
+
+	// the context is relative to the module so the module is not part of the path
+	if err := setContextVariableFromPath(ctx, fmt.Sprintf("%s.%s", b.Type, r.Metadata().Name), val); err != nil {
+		return createParserError(r, fmt.Sprintf(`unable to set context variable: %s`, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package hclconfig
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/test_fixtures/structs"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBlockTypesParser(t *testing.T, options ...*ParserOptions) *Parser {
+	p := setupParser(t, options...)
+
+	err := p.RegisterBlockType(resources.BlockType{
+		Name:     structs.TypeProvider,
+		Labels:   []string{"name"},
+		Resource: &structs.Provider{},
+		DAG:      false,
+	})
+	require.NoError(t, err)
+
+	err = p.RegisterBlockType(resources.BlockType{
+		Name:     structs.TypePipeline,
+		Labels:   []string{"type", "name"},
+		Resource: &structs.Pipeline{},
+		DAG:      true,
+	})
+	require.NoError(t, err)
+
+	return p
+}
+
+func TestParseCreatesRegisteredBlockTypes(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/blocks")
+	require.NoError(t, err)
+
+	p := setupBlockTypesParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("provider.docker")
+	require.NoError(t, err)
+
+	pr := r.(*structs.Provider)
+	require.Equal(t, "provider.docker", pr.Meta.ID)
+	require.Equal(t, "24.0", pr.Version)
+	require.Equal(t, "unix:///var/run/docker.sock", pr.Host)
+
+	r, err = c.FindResource("resource.container.builder")
+	require.NoError(t, err)
+
+	co := r.(*structs.Container)
+	require.Equal(t, []string{"docker", "--host", "unix:///var/run/docker.sock"}, co.Command)
+	require.Equal(t, "24.0", co.Env["DOCKER_VERSION"])
+
+	r, err = c.FindResource("pipeline.build.app")
+	require.NoError(t, err)
+
+	pi := r.(*structs.Pipeline)
+	require.Equal(t, "pipeline.build.app", pi.Meta.ID)
+	require.Equal(t, "builder", pi.Container)
+	require.Contains(t, pi.Meta.Links, "resource.container.builder.meta.name")
+
+	r, err = c.FindResource("output.steps")
+	require.NoError(t, err)
+	require.Equal(t, []any{"test", "build"}, r.(*resources.Output).Value)
+}
+
+func TestParseCallsCallbacksForRegisteredBlockTypes(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/blocks")
+	require.NoError(t, err)
+
+	mutex := sync.Mutex{}
+	calls := []string{}
+
+	o := DefaultOptions()
+	o.Callback = func(r types.Resource) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		calls = append(calls, r.Metadata().ID)
+		return nil
+	}
+
+	p := setupBlockTypesParser(t, o)
+
+	_, err = p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	require.Contains(t, calls, "provider.docker")
+	require.Less(t, slices.Index(calls, "resource.container.builder"), slices.Index(calls, "pipeline.build.app"))
+}
+
+func TestParseReturnsErrorWhenBlockNotInGraphReferencesResource(t *testing.T) {
+	p := setupBlockTypesParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "docker" {}
+
+provider "docker" {
+  version = resource.container.docker.meta.name
+}
+`))
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, 4, pe.Line)
+	require.Equal(t, `'provider' blocks are decoded when the configuration is parsed and can only reference variables, found reference to "resource.container.docker.meta.name"`, pe.Message)
+}
+
+func TestParseReturnsErrorForInvalidBlockLabels(t *testing.T) {
+	p := setupBlockTypesParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+pipeline "build" {
+  container = "builder"
+  steps     = []
+}
+`))
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Equal(t, `invalid formatting for 'pipeline' stanza, the block should have the labels type, name, i.e. 'pipeline "type" "name" {}'`, pe.Message)
+}
+
+func TestParseReturnsWarningForBlockNotRegisteredWithParser(t *testing.T) {
+	// the block type is registered by a different parser
+	setupBlockTypesParser(t)
+
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+provider "docker" {
+  version = "24.0"
+}
+`))
+	require.Error(t, err)
+	require.True(t, err.(*errors.ConfigError).ContainsWarnings())
+	require.False(t, err.(*errors.ConfigError).ContainsErrors())
+}
+
+func TestParseJSONCreatesRegisteredBlockTypes(t *testing.T) {
+	p := setupBlockTypesParser(t)
+
+	c, err := p.ParseSource("main.hcl.json", []byte(`{
+  "provider": {
+    "docker": {
+      "version": "24.0"
+    }
+  },
+  "pipeline": {
+    "build": {
+      "app": {
+        "container": "builder",
+        "steps": ["test"]
+      }
+    }
+  }
+}`))
+	require.NoError(t, err)
+
+	r, err := c.FindResource("provider.docker")
+	require.NoError(t, err)
+	require.Equal(t, "24.0", r.(*structs.Provider).Version)
+
+	r, err = c.FindResource("pipeline.build.app")
+	require.NoError(t, err)
+	require.Equal(t, []string{"test"}, r.(*structs.Pipeline).Steps)
+}
+
+func TestRegisterBlockTypeReturnsErrorForExistingKeyword(t *testing.T) {
+	p := setupBlockTypesParser(t)
+
+	err := p.RegisterBlockType(resources.BlockType{Name: resources.TypeOutput, Labels: []string{"name"}, Resource: &structs.Provider{}})
+	require.Error(t, err)
+
+	err = p.RegisterBlockType(resources.BlockType{Name: typeMoved, Labels: []string{"name"}, Resource: &structs.Provider{}})
+	require.Error(t, err)
+
+	err = p.RegisterBlockType(resources.BlockType{Name: structs.TypeProvider, Labels: []string{"name"}, Resource: &structs.Provider{}})
+	require.Error(t, err)
+}
+
+func TestRegisterTypeDoesNotReplaceRegisteredBlockTypes(t *testing.T) {
+	p := setupBlockTypesParser(t)
+	p.RegisterVersionedType(structs.TypeProvider, 2, &structs.Container{})
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+provider "docker" {
+  version = "24.0"
+}
+`))
+	require.NoError(t, err)
+
+	r, err := c.FindResource("provider.docker")
+	require.NoError(t, err)
+	require.IsType(t, &structs.Provider{}, r)
+	require.Equal(t, 0, r.Metadata().SchemaVersion)
+}
+
+func TestRegisterBlockTypeDoesNotAffectOtherParsers(t *testing.T) {
+	setupBlockTypesParser(t)
+
+	// a block with the same keyword but a different definition
+	p := setupParser(t)
+	err := p.RegisterBlockType(resources.BlockType{Name: structs.TypeProvider, Labels: []string{"type", "name"}, Resource: &structs.Provider{}})
+	require.NoError(t, err)
+
+	_, err = setupParser(t).ParseSource("main.hcl", []byte(`
+provider "docker" {}
+`))
+	require.Error(t, err)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+provider "docker" "local" {
+  version = "24.0"
+}
+`))
+	require.NoError(t, err)
+
+	_, err = c.FindResource("provider.docker.local")
+	require.NoError(t, err)
+}
+
+func TestParseAllowsResourcesNamedAfterRegisteredBlockTypes(t *testing.T) {
+	p := setupBlockTypesParser(t)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "provider" {}
+
+output "name" {
+  value = resource.container.provider.meta.name
+}
+`))
+	require.NoError(t, err)
+
+	r, err := c.FindResource("output.name")
+	require.NoError(t, err)
+	require.Equal(t, "provider", r.(*resources.Output).Value)
+}
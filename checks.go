@@ -0,0 +1,69 @@
+package hclconfig
+
+import (
+	"fmt"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+)
+
+// evaluateChecks evaluates the assertions of every check in the config, this is
+// done once all the resources have been processed. Failing assertions are returned
+// as errors or warnings depending on the severity of the check and the messages
+// are recorded on the check.
+func evaluateChecks(c *Config) []error {
+	checks, err := c.FindResourcesByType(resources.TypeCheck)
+	if err != nil {
+		return nil
+	}
+
+	errs := []error{}
+	for _, r := range checks {
+		if r.GetDisabled() {
+			continue
+		}
+
+		check := r.(*resources.Check)
+
+		level := errors.ParserErrorLevelError
+		switch check.Severity {
+		case "", resources.CheckSeverityError:
+		case resources.CheckSeverityWarning:
+			level = errors.ParserErrorLevelWarning
+		default:
+			errs = append(errs, createParserError(check, fmt.Sprintf(`invalid severity "%s" for check "%s", must be "%s" or "%s"`, check.Severity, check.Meta.ID, resources.CheckSeverityError, resources.CheckSeverityWarning)))
+			continue
+		}
+
+		ctx, err := c.getContext(check)
+		if err != nil {
+			errs = append(errs, createParserError(check, fmt.Sprintf(`unable to evaluate check "%s", no context found for the check`, check.Meta.ID)))
+			continue
+		}
+
+		// the asserts are decoded from the body with the marks removed, the
+		// original expressions are used so sensitive values are not exposed
+		conditions := []condition{}
+		for _, a := range check.Asserts {
+			conditions = append(conditions, condition{Condition: unwrapMarked(a.Condition), ErrorMessage: unwrapMarked(a.ErrorMessage)})
+		}
+
+		check.Failures = nil
+
+		c.locks.withContextLock(ctx, func() {
+			// set the final values of the referenced resources
+			if err := setContextVariablesFromList(c, check, check.Meta.Links, ctx); err != nil {
+				errs = append(errs, err)
+				return
+			}
+
+			for _, pe := range checkConditions(ctx, "assertion", fmt.Sprintf(`check "%s"`, check.Meta.ID), conditions) {
+				pe.Level = level
+				check.Failures = append(check.Failures, pe.Message)
+				errs = append(errs, pe)
+			}
+		})
+	}
+
+	return errs
+}
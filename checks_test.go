@@ -0,0 +1,122 @@
+package hclconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvaluatesChecks(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/checks")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	checks, err := c.FindResourcesByType(resources.TypeCheck)
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+
+	r, err := c.FindResource("check.availability")
+	require.NoError(t, err)
+
+	check := r.(*resources.Check)
+	require.Equal(t, resources.CheckSeverityWarning, check.Severity)
+	require.Len(t, check.Asserts, 2)
+	require.Empty(t, check.Failures)
+}
+
+func TestParseReturnsWarningWhenCheckWithWarningSeverityFails(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/checks")
+	require.NoError(t, err)
+
+	o := DefaultOptions()
+	o.Variables = map[string]string{"replicas": "1"}
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.Error(t, err)
+	require.NotNil(t, c)
+
+	ce := err.(*errors.ConfigError)
+	require.True(t, ce.ContainsWarnings())
+	require.False(t, ce.ContainsErrors())
+	require.Len(t, ce.Errors, 1)
+
+	pe := ce.Errors[0].(*errors.ParserError)
+	require.Equal(t, filepath.Join(absoluteFolderPath, "checks.hcl"), pe.Filename)
+	require.Equal(t, 39, pe.Line)
+	require.Equal(t, `assertion failed for check "check.availability": at least 2 replicas should be used for high availability, got 1`, pe.Message)
+
+	r, err := c.FindResource("check.availability")
+	require.NoError(t, err)
+	require.Equal(t, []string{pe.Message}, r.(*resources.Check).Failures)
+}
+
+func TestParseReturnsErrorWhenCheckFails(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "app" {
+  resources {
+    cpu = 8000
+  }
+}
+
+check "cpu" {
+  assert {
+    condition     = resource.container.app.resources.cpu <= 4000
+    error_message = "the container must not use more than 4000 cpu"
+  }
+}
+`))
+	require.Error(t, err)
+
+	ce := err.(*errors.ConfigError)
+	require.True(t, ce.ContainsErrors())
+	require.Len(t, ce.Errors, 1)
+
+	pe := ce.Errors[0].(*errors.ParserError)
+	require.Equal(t, 10, pe.Line)
+	require.Equal(t, `assertion failed for check "check.cpu": the container must not use more than 4000 cpu`, pe.Message)
+}
+
+func TestParseReturnsErrorForInvalidCheckSeverity(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+check "cpu" {
+  severity = "fatal"
+
+  assert {
+    condition     = true
+    error_message = "never returned"
+  }
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, `invalid severity "fatal" for check "check.cpu"`)
+}
+
+func TestEvaluateChecksReturnsErrorWhenCheckHasNoContext(t *testing.T) {
+	c := NewConfig()
+
+	check := &resources.Check{}
+	check.Meta.ID = "check.availability"
+	check.Meta.Type = resources.TypeCheck
+	check.Meta.Name = "availability"
+	c.Resources = append(c.Resources, check)
+
+	errs := evaluateChecks(c)
+	require.Len(t, errs, 1)
+
+	pe := errs[0].(*errors.ParserError)
+	require.Equal(t, errors.ParserErrorLevelError, pe.Level)
+	require.Contains(t, pe.Message, `unable to evaluate check "check.availability"`)
+}
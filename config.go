@@ -2,29 +2,51 @@ package hclconfig
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/jumppad-labs/hclconfig/errors"
 	"github.com/jumppad-labs/hclconfig/resources"
 	"github.com/jumppad-labs/hclconfig/types"
 	"github.com/silas/dag"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // Config defines the stack config
 type Config struct {
 	Resources []types.Resource `json:"resources"`
 	contexts  map[types.Resource]*hcl.EvalContext
-	bodies    map[types.Resource]*hclsyntax.Body
+	bodies    map[types.Resource]hcl.Body
+	locks     *contextLocks
 	sync      sync.Mutex
+
+	// empty contains the FQRN of resources that have been expanded with count
+	// or for_each into no instances, this allows references to resources with
+	// no instances to be resolved. Resources with instances are found using
+	// the index or key of the instances.
+	empty map[string]bool
+
+	// sensitive contains the sensitive string values that have been set in the
+	// config, these are redacted from errors
+	sensitive map[string]bool
+
+	// moved contains the resources that have been renamed or moved into a
+	// different module using moved blocks
+	moved []moved
+
+	// blockTypes are the top level blocks of the parser that created the
+	// config, they are used to parse references to resources
+	blockTypes resources.BlockTypes
 }
 
 // ResourceNotFoundError is thrown when a resource could not be found
@@ -48,10 +70,14 @@ func (e ResourceExistsError) Error() string {
 // New creates a new Config
 func NewConfig() *Config {
 	c := &Config{
-		Resources: []types.Resource{},
-		contexts:  map[types.Resource]*hcl.EvalContext{},
-		bodies:    map[types.Resource]*hclsyntax.Body{},
-		sync:      sync.Mutex{},
+		Resources:  []types.Resource{},
+		contexts:   map[types.Resource]*hcl.EvalContext{},
+		bodies:     map[types.Resource]hcl.Body{},
+		locks:      &contextLocks{},
+		sync:       sync.Mutex{},
+		empty:      map[string]bool{},
+		sensitive:  map[string]bool{},
+		blockTypes: resources.DefaultBlockTypes(),
 	}
 
 	return c
@@ -74,6 +100,15 @@ func NewConfig() *Config {
 //
 // e.g. to find a cluster named k3s in the module module1
 // r, err := c.FindResource("module.module1.resource.cluster.k3s")
+//
+// e.g. to find a data source for a cluster named k3s
+// r, err := c.FindResource("data.cluster.k3s")
+//
+// e.g. to find the third instance of a cluster named k3s that uses count
+// r, err := c.FindResource("resource.cluster.k3s[2]")
+//
+// e.g. to find the instance with the key dev of a cluster named k3s that uses for_each
+// r, err := c.FindResource(`resource.cluster.k3s["dev"]`)
 func (c *Config) FindResource(path string) (types.Resource, error) {
 	c.sync.Lock()
 	defer c.sync.Unlock()
@@ -83,7 +118,7 @@ func (c *Config) FindResource(path string) (types.Resource, error) {
 
 // local version of FindResource that does not lock the config
 func (c *Config) findResource(path string) (types.Resource, error) {
-	fqdn, err := resources.ParseFQRN(path)
+	fqdn, err := c.blockTypes.ParseFQRN(path)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +132,10 @@ func (c *Config) findResource(path string) (types.Resource, error) {
 	for _, r := range c.Resources {
 		if r.Metadata().Module == fqdn.Module &&
 			r.Metadata().Type == fqdn.Type &&
-			r.Metadata().Name == fqdn.Resource {
+			r.Metadata().Name == fqdn.Resource &&
+			r.Metadata().Data == fqdn.Data &&
+			equalPtr(r.Metadata().Index, fqdn.Index) &&
+			equalPtr(r.Metadata().Key, fqdn.Key) {
 			return r, nil
 		}
 	}
@@ -105,11 +143,90 @@ func (c *Config) findResource(path string) (types.Resource, error) {
 	return nil, ResourceNotFoundError{fqdn.StringWithoutAttribute()}
 }
 
+// findInstances returns the instances for a resource that has been expanded
+// with count or for_each ordered by their index or key, when the fqrn contains
+// an index or key only that instance is returned. The boolean return value is
+// false when the resource has not been expanded.
+func (c *Config) findInstances(fqrn resources.FQRN) ([]types.Resource, bool) {
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	index := fqrn.Index
+	key := fqrn.Key
+	fqrn.Index = nil
+	fqrn.Key = nil
+	fqrn.Attribute = ""
+
+	expanded := c.empty[fqrn.String()]
+
+	instances := []types.Resource{}
+	for _, r := range c.Resources {
+		m := r.Metadata()
+		if m.Module != fqrn.Module || m.Type != fqrn.Type || m.Name != fqrn.Resource || m.Data != fqrn.Data {
+			continue
+		}
+
+		if m.Index == nil && m.Key == nil {
+			continue
+		}
+
+		expanded = true
+
+		if (index != nil || key != nil) && (!equalPtr(index, m.Index) || !equalPtr(key, m.Key)) {
+			continue
+		}
+
+		instances = append(instances, r)
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		a := instances[i].Metadata()
+		b := instances[j].Metadata()
+
+		if a.Index != nil && b.Index != nil {
+			return *a.Index < *b.Index
+		}
+
+		if a.Key != nil && b.Key != nil {
+			return *a.Key < *b.Key
+		}
+
+		return false
+	})
+
+	if !expanded {
+		return nil, false
+	}
+
+	return instances, true
+}
+
+// addEmpty records that the resource has been expanded into no instances
+func (c *Config) addEmpty(fqrn resources.FQRN) {
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	fqrn.Index = nil
+	fqrn.Key = nil
+	fqrn.Attribute = ""
+
+	c.empty[fqrn.String()] = true
+}
+
+// equalPtr returns true when both pointers are nil or have the same value
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
 func (c *Config) FindRelativeResource(path string, parentModule string) (types.Resource, error) {
 	c.sync.Lock()
 	defer c.sync.Unlock()
 
-	fqdn, err := resources.ParseFQRN(path)
+	fqdn, err := c.blockTypes.ParseFQRN(path)
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +275,7 @@ func (c *Config) FindModuleResources(module string, includeSubModules bool) ([]t
 	c.sync.Lock()
 	defer c.sync.Unlock()
 
-	fqdn, err := resources.ParseFQRN(module)
+	fqdn, err := c.blockTypes.ParseFQRN(module)
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +284,7 @@ func (c *Config) FindModuleResources(module string, includeSubModules bool) ([]t
 		return nil, fmt.Errorf("resource %s is not a module reference", module)
 	}
 
-	moduleString := fmt.Sprintf("%s.%s", fqdn.Module, fqdn.Resource)
+	moduleString := fmt.Sprintf("%s.%s%s", fqdn.Module, fqdn.Resource, resources.InstanceSelector(fqdn.Index, fqdn.Key))
 	moduleString = strings.TrimPrefix(moduleString, ".")
 
 	resources := []types.Resource{}
@@ -206,8 +323,14 @@ func (c *Config) AppendResourcesFromConfig(new *Config) error {
 	c.sync.Lock()
 	defer c.sync.Unlock()
 
+	// resources defined by blocks registered with the parser of the new config
+	// are referenced using the keyword of the block
+	blockTypes := maps.Clone(c.blockTypes)
+	maps.Copy(blockTypes, new.blockTypes)
+	c.blockTypes = blockTypes
+
 	for _, r := range new.Resources {
-		fqdn := resources.FQRNFromResource(r).String()
+		fqdn := c.blockTypes.FQRNFromResource(r).String()
 
 		// does the resource already exist?
 		if _, err := c.findResource(fqdn); err == nil {
@@ -234,12 +357,30 @@ func (c *Config) AppendResource(r types.Resource) error {
 // ToJSON converts the config to a serializable json string
 // to unmarshal the output of this method back into a config you can use
 // the Parser.UnmarshalJSON method
+//
+// Sensitive values are replaced with types.RedactedValue, to serialize the
+// config including sensitive values use ToJSONUnredacted.
 func (c *Config) ToJSON() ([]byte, error) {
+	rs, err := redactedResources(c.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode config: %s", err)
+	}
+
+	return encodeJSON(map[string]any{"resources": rs})
+}
+
+// ToJSONUnredacted converts the config to a serializable json string
+// including the value of any sensitive attributes and outputs
+func (c *Config) ToJSONUnredacted() ([]byte, error) {
+	return encodeJSON(map[string]any{"resources": c.Resources})
+}
+
+func encodeJSON(v any) ([]byte, error) {
 	buf := bytes.NewBuffer([]byte{})
 	enc := json.NewEncoder(buf)
 
 	enc.SetIndent("", " ")
-	err := enc.Encode(c)
+	err := enc.Encode(v)
 	if err != nil {
 		return nil, fmt.Errorf("unable to encode config: %s", err)
 	}
@@ -247,6 +388,41 @@ func (c *Config) ToJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// addSensitiveValues records the strings in the value so that they can be
+// redacted from errors
+func (c *Config) addSensitiveValues(val cty.Value) {
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	for _, s := range sensitiveStrings(val) {
+		c.sensitive[s] = true
+	}
+}
+
+// redactErrors replaces the sensitive values that have been set in the config
+// in any parser errors
+func (c *Config) redactErrors(err error) {
+	ce, ok := err.(*errors.ConfigError)
+	if !ok {
+		return
+	}
+
+	c.sync.Lock()
+	values := []string{}
+	for s := range c.sensitive {
+		values = append(values, s)
+	}
+	c.sync.Unlock()
+
+	// replace the longest values first so that values containing other
+	// values are fully redacted
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	ce.Redact(values, types.RedactedValue)
+}
+
 // ResourceDiff is a container for resources that have changed between
 // two different configurations
 type ResourceDiff struct {
@@ -265,6 +441,13 @@ type ResourceDiff struct {
 	Removed []types.Resource
 	// Resources that have not changed
 	Unchanged []types.Resource
+	// Resources that have been renamed or moved into a different module using a
+	// moved block and have not changed, moved resources that have changed are
+	// returned in ParseUpdated
+	Moved []types.Resource
+	// MovedFrom maps the ID of every moved resource to its ID in the previous
+	// configuration
+	MovedFrom map[string]string
 }
 
 // Diff compares the current configuration to the provided configuration and
@@ -275,6 +458,8 @@ func (c *Config) Diff(o *Config) (*ResourceDiff, error) {
 	var processChanged []types.Resource
 	var removed []types.Resource
 	var unchanged []types.Resource
+	var moved []types.Resource
+	movedFrom := map[string]string{}
 
 	for _, r := range o.Resources {
 		// does the resource exist
@@ -282,6 +467,19 @@ func (c *Config) Diff(o *Config) (*ResourceDiff, error) {
 
 		// check if the resource has been found
 		if err != nil {
+			// has the resource been moved from a resource that no longer exists
+			if cr, ok := c.findMovedResource(o, r); ok {
+				movedFrom[r.Metadata().ID] = cr.Metadata().ID
+
+				if movedChecksum(cr) != movedChecksum(r) {
+					parseChanged = append(parseChanged, r)
+					continue
+				}
+
+				moved = append(moved, r)
+				continue
+			}
+
 			// resource does not exist
 			new = append(new, r)
 			continue
@@ -301,9 +499,19 @@ func (c *Config) Diff(o *Config) (*ResourceDiff, error) {
 		}
 	}
 
+	// resources that have been moved are neither removed nor unchanged
+	previous := map[string]bool{}
+	for _, id := range movedFrom {
+		previous[id] = true
+	}
+
 	// check if there are resources in the state that are no longer
 	// in the config
 	for _, r := range c.Resources {
+		if previous[r.Metadata().ID] {
+			continue
+		}
+
 		found := false
 		for _, r2 := range o.Resources {
 			if r.Metadata().ID == r2.Metadata().ID {
@@ -319,7 +527,7 @@ func (c *Config) Diff(o *Config) (*ResourceDiff, error) {
 
 	// now add any unchanged resources
 	for _, r := range c.Resources {
-		found := false
+		found := previous[r.Metadata().ID]
 		for _, r2 := range new {
 			if r.Metadata().ID == r2.Metadata().ID {
 				found = true
@@ -359,6 +567,8 @@ func (c *Config) Diff(o *Config) (*ResourceDiff, error) {
 		ParseUpdated:     parseChanged,
 		ProcessedUpdated: processChanged,
 		Unchanged:        unchanged,
+		Moved:            moved,
+		MovedFrom:        movedFrom,
 	}, nil
 
 }
@@ -371,7 +581,10 @@ func (c *Config) RemoveResource(rf types.Resource) error {
 	for i, r := range c.Resources {
 		if rf.Metadata().Name == r.Metadata().Name &&
 			rf.Metadata().Type == r.Metadata().Type &&
-			rf.Metadata().Module == r.Metadata().Module {
+			rf.Metadata().Data == r.Metadata().Data &&
+			rf.Metadata().Module == r.Metadata().Module &&
+			equalPtr(rf.Metadata().Index, r.Metadata().Index) &&
+			equalPtr(rf.Metadata().Key, r.Metadata().Key) {
 			pos = i
 			break
 		}
@@ -382,9 +595,17 @@ func (c *Config) RemoveResource(rf types.Resource) error {
 	if pos > -1 {
 		c.Resources = append(c.Resources[:pos], c.Resources[pos+1:]...)
 
-		// clean up the context and body
+		// clean up the context, body and locks, contexts can be shared
+		// so only remove the lock when no other resource uses the context
+		ctx := c.contexts[rf]
 		delete(c.contexts, rf)
 		delete(c.bodies, rf)
+		c.locks.remove(rf)
+
+		if !c.usesContext(ctx) {
+			c.locks.remove(ctx)
+		}
+
 		return nil
 	}
 
@@ -394,6 +615,10 @@ func (c *Config) RemoveResource(rf types.Resource) error {
 // WalkCallback is called with the resource when the graph processes that particular node
 type WalkCallback func(r types.Resource) error
 
+// WalkContextCallback is called with the context and the resource when the graph
+// processes that particular node
+type WalkContextCallback func(ctx context.Context, r types.Resource) error
+
 // Walk creates a Directed Acyclic Graph for the configuration resources depending on their
 // links and references. All the resources defined in the graph are traversed and
 // the provided callback is executed for every resource in the graph.
@@ -404,6 +629,19 @@ type WalkCallback func(r types.Resource) error
 // Specifying the reverse option to 'true' causes the graph to be traversed in reverse
 // order.
 func (c *Config) Walk(wf WalkCallback, reverse bool) error {
+	return c.WalkWithContext(
+		context.Background(),
+		func(ctx context.Context, r types.Resource) error {
+			return wf(r)
+		},
+		reverse,
+	)
+}
+
+// WalkWithContext is the same as Walk, the context is passed to every callback.
+// When the context is cancelled no further callbacks are executed and the returned
+// ConfigError contains a CancelledError listing the resources that were not processed.
+func (c *Config) WalkWithContext(ctx context.Context, wf WalkContextCallback, reverse bool) error {
 	// We need to ensure that Process does not execute the callback when
 	// any other callback returns an error.
 	// Unfortunately returning an error with tfdiags does not stop the walk
@@ -412,6 +650,7 @@ func (c *Config) Walk(wf WalkCallback, reverse bool) error {
 	pe := errors.NewConfigError()
 
 	errs := c.walk(
+		ctx,
 		func(v dag.Vertex) (diags dag.Diagnostics) {
 
 			r, ok := v.(types.Resource)
@@ -430,7 +669,7 @@ func (c *Config) Walk(wf WalkCallback, reverse bool) error {
 				return nil
 			}
 
-			err := wf(r)
+			err := wf(ctx, r)
 			if err != nil {
 				// set the global error mutex to stop further processing
 				hasError.Store(true)
@@ -457,7 +696,10 @@ func (c *Config) Walk(wf WalkCallback, reverse bool) error {
 // Until parse is called the HCL configuration is not deserialized into
 // the structs. We have to do this using a graph as some inputs depend on
 // outputs from other resources, therefore we need to process this is strict order
-func (c *Config) walk(wf dag.WalkFunc, reverse bool) []error {
+//
+// once the context is done the remaining nodes are not processed, a CancelledError
+// is returned containing the resources that were skipped
+func (c *Config) walk(ctx context.Context, wf dag.WalkFunc, reverse bool) []error {
 	// build the graph
 	d, err := doYaLikeDAGs(c)
 	if err != nil {
@@ -473,9 +715,24 @@ func (c *Config) walk(wf dag.WalkFunc, reverse bool) []error {
 		return []error{fmt.Errorf("unable to validate dependency graph: %w", err)}
 	}
 
+	skipped := []string{}
+	skippedSync := sync.Mutex{}
+
 	// define the walker callback that will be called for every node in the graph
 	w := dag.Walker{}
-	w.Callback = wf
+	w.Callback = func(v dag.Vertex) dag.Diagnostics {
+		if ctx.Err() != nil {
+			if r, ok := v.(types.Resource); ok && r.Metadata().Type != resources.TypeRoot {
+				skippedSync.Lock()
+				skipped = append(skipped, r.Metadata().ID)
+				skippedSync.Unlock()
+			}
+
+			return nil
+		}
+
+		return wf(v)
+	}
 	w.Reverse = reverse
 
 	// update the dag and process the nodes
@@ -486,15 +743,22 @@ func (c *Config) walk(wf dag.WalkFunc, reverse bool) []error {
 	diags := w.Wait()
 	if diags.HasErrors() {
 		errs = append(errs, diags.Err().(errwrap.Wrapper).WrappedErrors()...)
+	}
 
+	if ctx.Err() != nil {
+		sort.Strings(skipped)
+		errs = append(errs, &errors.CancelledError{Resources: skipped, Err: ctx.Err()})
+	}
+
+	if len(errs) > 0 {
 		return errs
 	}
 
 	return nil
 }
 
-func (c *Config) addResource(r types.Resource, ctx *hcl.EvalContext, b *hclsyntax.Body) error {
-	fqdn := resources.FQRNFromResource(r)
+func (c *Config) addResource(r types.Resource, ctx *hcl.EvalContext, b hcl.Body) error {
+	fqdn := c.blockTypes.FQRNFromResource(r)
 
 	// set the ID
 	r.Metadata().ID = fqdn.String()
@@ -519,10 +783,21 @@ func (c *Config) getContext(rf types.Resource) (*hcl.EvalContext, error) {
 	return nil, ResourceNotFoundError{}
 }
 
-func (c *Config) getBody(rf types.Resource) (*hclsyntax.Body, error) {
+func (c *Config) getBody(rf types.Resource) (hcl.Body, error) {
 	if b, ok := c.bodies[rf]; ok {
 		return b, nil
 	}
 
 	return nil, ResourceNotFoundError{}
 }
+
+// usesContext returns true if any resource in the config uses the given context
+func (c *Config) usesContext(ctx *hcl.EvalContext) bool {
+	for _, v := range c.contexts {
+		if v == ctx {
+			return true
+		}
+	}
+
+	return false
+}
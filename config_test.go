@@ -1,10 +1,14 @@
 package hclconfig
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
 
+	hclerrors "github.com/jumppad-labs/hclconfig/errors"
 	"github.com/jumppad-labs/hclconfig/resources"
 	"github.com/jumppad-labs/hclconfig/test_fixtures/structs"
 	"github.com/jumppad-labs/hclconfig/types"
@@ -295,6 +299,65 @@ func TestRemoveResourceRemoves(t *testing.T) {
 	require.Len(t, c.Resources, 10)
 }
 
+func TestRemoveResourceRemovesCountInstance(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/count")
+	require.NoError(t, err)
+
+	c, err := setupParser(t).ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.worker[2]")
+	require.NoError(t, err)
+
+	err = c.RemoveResource(r)
+	require.NoError(t, err)
+
+	_, err = c.FindResource("resource.container.worker[2]")
+	require.Error(t, err)
+
+	for i := range 2 {
+		_, err = c.FindResource(fmt.Sprintf("resource.container.worker[%d]", i))
+		require.NoError(t, err)
+	}
+}
+
+func TestRemoveResourceRemovesForEachInstance(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/for_each")
+	require.NoError(t, err)
+
+	c, err := setupParser(t).ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource(`resource.container.app["web"]`)
+	require.NoError(t, err)
+
+	err = c.RemoveResource(r)
+	require.NoError(t, err)
+
+	_, err = c.FindResource(`resource.container.app["web"]`)
+	require.Error(t, err)
+
+	_, err = c.FindResource(`resource.container.app["api"]`)
+	require.NoError(t, err)
+}
+
+func TestRemoveResourceReleasesLocks(t *testing.T) {
+	c, _ := testSetupConfig(t)
+	r := c.Resources[0]
+
+	unlock := c.locks.getResourceLock(r)
+	unlock()
+
+	_, ok := c.locks.locks.Load(r)
+	require.True(t, ok)
+
+	err := c.RemoveResource(r)
+	require.NoError(t, err)
+
+	_, ok = c.locks.locks.Load(r)
+	require.False(t, ok)
+}
+
 func TestRemoveResourceNotFoundReturnsError(t *testing.T) {
 	typs := resources.DefaultResources()
 	typs[structs.TypeNetwork] = &structs.Network{}
@@ -446,6 +509,60 @@ func TestProcessCallbackErrorHaltsExecution(t *testing.T) {
 	require.Equal(t, 1, len(calls))
 }
 
+func TestWalkWithContextPassesContextToCallback(t *testing.T) {
+	c, _ := testSetupConfig(t)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	err := c.WalkWithContext(
+		ctx,
+		func(ctx context.Context, r types.Resource) error {
+			require.Equal(t, "value", ctx.Value(key{}))
+			return nil
+		},
+		false,
+	)
+
+	require.NoError(t, err)
+}
+
+func TestWalkWithContextStopsWhenCancelled(t *testing.T) {
+	c, _ := testSetupConfig(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := []string{}
+	callSync := sync.Mutex{}
+	err := c.WalkWithContext(
+		ctx,
+		func(ctx context.Context, r types.Resource) error {
+			callSync.Lock()
+			calls = append(calls, r.Metadata().ID)
+			callSync.Unlock()
+
+			if r.Metadata().Name == "cloud" {
+				cancel()
+			}
+
+			return nil
+		},
+		false,
+	)
+
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.Canceled)
+
+	ce := &hclerrors.CancelledError{}
+	require.True(t, errors.As(err, &ce))
+
+	// resources that depend on cloud must not have been processed
+	require.NotContains(t, calls, "module.module1.resource.container.test_dev")
+	require.Contains(t, ce.Resources, "module.module1.resource.container.test_dev")
+	require.Contains(t, ce.Resources, "output.out")
+}
+
 func copyConfig(t *testing.T, c *Config) *Config {
 	d, _ := c.ToJSON()
 	p := setupParser(t)
@@ -7,21 +7,36 @@ import (
 	"github.com/jumppad-labs/hclconfig/types"
 )
 
-var locks = sync.Map{}
+// contextLocks ensures that HCL Contexts and resources are not written and read
+// at the same time. Locks are owned by a Config and are released with it.
+type contextLocks struct {
+	locks sync.Map
+}
 
 // withContextLock ensures that a HCL Context is not written and read
-// at the same time
-func withContextLock(ctx *hcl.EvalContext, call func()) {
-	lock, _ := locks.LoadOrStore(ctx, &sync.Mutex{})
+// at the same time, child contexts read the variables of their parents
+// so the parent contexts are also locked
+func (l *contextLocks) withContextLock(ctx *hcl.EvalContext, call func()) {
+	// always obtain the locks starting from the root context so that
+	// contexts sharing a parent can not deadlock
+	contexts := []*hcl.EvalContext{}
+	for c := ctx; c != nil; c = c.Parent() {
+		contexts = append([]*hcl.EvalContext{c}, contexts...)
+	}
+
+	for _, c := range contexts {
+		lock, _ := l.locks.LoadOrStore(c, &sync.Mutex{})
+
+		// obtain a lock
+		lock.(*sync.Mutex).Lock()
+		defer lock.(*sync.Mutex).Unlock()
+	}
 
-	// obtain a lock
-	lock.(*sync.Mutex).Lock()
-	defer lock.(*sync.Mutex).Unlock()
 	call()
 }
 
-func getResourceLock(r types.Resource) func() {
-	lock, _ := locks.LoadOrStore(r, &sync.Mutex{})
+func (l *contextLocks) getResourceLock(r types.Resource) func() {
+	lock, _ := l.locks.LoadOrStore(r, &sync.Mutex{})
 
 	// obtain a lock
 	lock.(*sync.Mutex).Lock()
@@ -31,3 +46,8 @@ func getResourceLock(r types.Resource) func() {
 		lock.(*sync.Mutex).Unlock()
 	}
 }
+
+// remove deletes the lock for the given resource or context
+func (l *contextLocks) remove(key any) {
+	l.locks.Delete(key)
+}
@@ -12,13 +12,14 @@ import (
 
 func TestContextLockDoesNotAllowConcurrentAccesstoContext(t *testing.T) {
 	a := &hcl.EvalContext{Variables: map[string]cty.Value{}}
+	l := &contextLocks{}
 
 	w := sync.WaitGroup{}
 	w.Add(2)
 
 	go func() {
 		// get a lock but never unlock it
-		withContextLock(a, func() {
+		l.withContextLock(a, func() {
 			for i := 0; i < 100; i++ {
 				a.Variables[fmt.Sprintf("%d", i)] = cty.StringVal("bar")
 			}
@@ -28,7 +29,7 @@ func TestContextLockDoesNotAllowConcurrentAccesstoContext(t *testing.T) {
 	}()
 
 	go func() {
-		withContextLock(a, func() {
+		l.withContextLock(a, func() {
 			for i := 0; i < 100; i++ {
 				a.Variables[fmt.Sprintf("%d", i)] = cty.StringVal("bar")
 			}
@@ -56,12 +57,13 @@ func TestContextLockDoesNotAllowConcurrentAccesstoContext(t *testing.T) {
 func TestContextLockAllowsConcurrentAccesstoDifferentContexts(t *testing.T) {
 	a := &hcl.EvalContext{Variables: map[string]cty.Value{}}
 	b := &hcl.EvalContext{Variables: map[string]cty.Value{}}
+	l := &contextLocks{}
 
 	w := sync.WaitGroup{}
 	w.Add(2)
 
 	go func() {
-		withContextLock(a, func() {
+		l.withContextLock(a, func() {
 			for i := 0; i < 100; i++ {
 				a.Variables[fmt.Sprintf("%d", i)] = cty.StringVal("bar")
 			}
@@ -71,7 +73,7 @@ func TestContextLockAllowsConcurrentAccesstoDifferentContexts(t *testing.T) {
 	}()
 
 	go func() {
-		withContextLock(a, func() {
+		l.withContextLock(a, func() {
 			for i := 0; i < 100; i++ {
 				b.Variables[fmt.Sprintf("%d", i)] = cty.StringVal("bar")
 			}
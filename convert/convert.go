@@ -3,18 +3,26 @@ package convert
 import (
 	"fmt"
 
+	"github.com/jumppad-labs/hclconfig/resources"
 	"github.com/jumppad-labs/hclconfig/types"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/gocty"
 )
 
 func GoToCtyValue(val any) (cty.Value, error) {
-	typ, err := gocty.ImpliedType(val)
+	// resources defined by a schema store their values in a struct that is
+	// created at runtime
+	target := val
+	if sr, ok := val.(*resources.SchemaResource); ok {
+		target = sr.Value()
+	}
+
+	typ, err := gocty.ImpliedType(target)
 	if err != nil {
 		return cty.False, err
 	}
 
-	ctyVal, err := gocty.ToCtyValue(val, typ)
+	ctyVal, err := gocty.ToCtyValue(target, typ)
 	if err != nil {
 		return cty.False, err
 	}
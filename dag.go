@@ -10,6 +10,7 @@ import (
 
 	"github.com/creasty/defaults"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/dynblock"
 	"github.com/hashicorp/hcl/v2/gohcl"
 
 	"github.com/jumppad-labs/hclconfig/convert"
@@ -33,8 +34,8 @@ func doYaLikeDAGs(c *Config) (*dag.AcyclicGraph, error) {
 
 	// Loop over all resources and add to graph
 	for _, resource := range c.Resources {
-		// ignore variables
-		if resource.Metadata().Type != resources.TypeVariable {
+		// ignore variables and blocks that are not processed in the graph
+		if c.inGraph(resource) {
 			graph.Add(resource)
 		}
 	}
@@ -43,8 +44,8 @@ func doYaLikeDAGs(c *Config) (*dag.AcyclicGraph, error) {
 	for _, resource := range c.Resources {
 		hasDeps := false
 
-		// do nothing with variables
-		if resource.Metadata().Type == resources.TypeVariable {
+		// do nothing with variables or blocks that are not processed in the graph
+		if !c.inGraph(resource) {
 			continue
 		}
 
@@ -60,7 +61,7 @@ func doYaLikeDAGs(c *Config) (*dag.AcyclicGraph, error) {
 
 		for _, d := range resource.GetDependencies() {
 			var err error
-			fqdn, err := resources.ParseFQRN(d)
+			fqdn, err := c.blockTypes.ParseFQRN(d)
 			if err != nil {
 				return nil, createParserError(resource, fmt.Sprintf("invalid dependency '%s': %s", d, err))
 			}
@@ -92,10 +93,25 @@ func doYaLikeDAGs(c *Config) (*dag.AcyclicGraph, error) {
 				// "module.module1.module2.resource.container.mine.id"
 				relFQDN := fqdn.AppendParentModule(resource.Metadata().Module)
 
+				// when the dependency is a resource that has been expanded with count
+				// or for_each depend on the referenced instance or all the instances
+				if instances, ok := c.findInstances(relFQDN); ok {
+					for _, dep := range instances {
+						dependencies[dep] = true
+					}
+
+					continue
+				}
+
 				// we ignore the error here as it may be possible that the module depends on
 				// disabled resources
 				dep, _ := c.FindResource(relFQDN.String())
 
+				// resources that are not in the graph have already been processed
+				if dep != nil && !c.inGraph(dep) {
+					continue
+				}
+
 				dependencies[dep] = true
 			}
 		}
@@ -133,8 +149,9 @@ func doYaLikeDAGs(c *Config) (*dag.AcyclicGraph, error) {
 // createCallback creates the internal callback that is called when a node in the
 // dag is visited. This callback is responsible for processing the resource, setting
 // any linked values and calling the user defined callback so that external work
-// can be performed
-func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Diagnostics) {
+// can be performed. Postconditions are only checked when process is true, they
+// refer to values that are set when the resource is processed.
+func createCallback(c *Config, wf WalkCallback, process bool) func(v dag.Vertex) (diags dag.Diagnostics) {
 	return func(v dag.Vertex) (diags dag.Diagnostics) {
 		r, ok := v.(types.Resource)
 		// not a resource skip, this should never happen
@@ -143,7 +160,7 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 		}
 
 		// ensure that the resource is written to by other processes
-		l := getResourceLock(r)
+		l := c.locks.getResourceLock(r)
 		defer l()
 
 		// if this is the root module or is disabled skip or is a variable
@@ -185,8 +202,8 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 		// This expression could be a reference to another resource or it could be a
 		// function or a conditional statement. We need to evaluate the expression
 		// to determine if the resource should be disabled
-		if attr, ok := bdy.Attributes["disabled"]; ok {
-			resources, err := processExpr(attr.Expr)
+		if attr := getAttribute(bdy, "disabled"); attr != nil {
+			resources, err := exprReferences(c.blockTypes, attr.Expr)
 
 			// need to handle this error
 			if err != nil {
@@ -200,7 +217,7 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 			if len(resources) > 0 {
 
 				// first we need to build the context for the expression
-				withContextLock(ctx, func() {
+				c.locks.withContextLock(ctx, func() {
 					err := setContextVariablesFromList(c, r, resources, ctx)
 					if err != nil {
 						diags = diags.Append(err)
@@ -213,8 +230,8 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 
 				// now we need to evaluate the expression
 				expdiags := hcl.Diagnostics{}
-				withContextLock(ctx, func() {
-					expdiags = gohcl.DecodeExpression(attr.Expr, ctx, &isDisabled)
+				c.locks.withContextLock(ctx, func() {
+					expdiags = gohcl.DecodeExpression(&unmarkExpr{Expression: attr.Expr}, ctx, &isDisabled)
 				})
 
 				if expdiags.HasErrors() {
@@ -229,7 +246,7 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 		}
 
 		// set the context variables from the linked resources
-		withContextLock(ctx, func() {
+		c.locks.withContextLock(ctx, func() {
 			err := setContextVariablesFromList(c, r, r.Metadata().Links, ctx)
 
 			if err != nil {
@@ -242,15 +259,43 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 		}
 
 		// if there are defaults defined on the resource set them
-		defaults.Set(r)
+		defaults.Set(resourceValue(r))
+
+		// the lifecycle block contains the conditions for the resource and is
+		// not part of the resource
+		lc, bdy, err := decodeLifecycle(c, r, bdy)
+		if err != nil {
+			return diags.Append(err)
+		}
 
 		// process the raw resource now we have the context from the linked
-		// resources
+		// resources, dynamic blocks are expanded into the nested blocks that
+		// they generate before the body is decoded.
+		// sensitive values can not be decoded into Go types, the marks are removed
+		// and the attributes that contained them are recorded
 		decodeDiags := hcl.Diagnostics{}
-		withContextLock(ctx, func() {
-			decodeDiags = gohcl.DecodeBody(bdy, ctx, r)
+		sensitive := []string{}
+		var schemaErr *errors.ParserError
+		c.locks.withContextLock(ctx, func() {
+			body := newUnmarkBody(dynblock.Expand(bdy, ctx), func(path string, val cty.Value) {
+				sensitive = append(sensitive, path)
+				c.addSensitiveValues(val)
+			})
+
+			if schemaErr = validateSchemaBody(r, body); schemaErr != nil {
+				return
+			}
+
+			decodeDiags = gohcl.DecodeBody(body, ctx, resourceValue(r))
 		})
 
+		if schemaErr != nil {
+			return diags.Append(schemaErr)
+		}
+
+		slices.Sort(sensitive)
+		r.Metadata().Sensitive = slices.Compact(sensitive)
+
 		if decodeDiags.HasErrors() {
 			// this error is set as warning as it is possible that the resource has
 			// interpolation that is not yet resolved.
@@ -288,6 +333,10 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 					"Invalid nested splat expressions",
 					"Function calls not allowed",
 					"Unsupported argument",
+					"Invalid dynamic for_each value",
+					"Invalid dynamic iterator name",
+					"Missing dynamic content block",
+					"Extraneous dynamic content block",
 				}
 
 				if slices.Contains(errorSummaries, err.Summary) {
@@ -325,17 +374,74 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 			// now set the context variables from the modules variables
 			mod := r.(*resources.Module)
 
-			withContextLock(ctx, func() {
+			// the variables declared by the module are used to convert the inputs
+			// to the declared types
+			declared := map[string]*resources.Variable{}
+			if mr, err := c.FindModuleResources(r.Metadata().ID, false); err == nil {
+				for _, d := range mr {
+					if v, ok := d.(*resources.Variable); ok {
+						declared[v.Meta.Name] = v
+					}
+				}
+			}
+
+			c.locks.withContextLock(ctx, func() {
 				var mapVars map[string]cty.Value
 				if att, ok := mod.Variables.(*hcl.Attribute); ok {
-					val, _ := att.Expr.Value(ctx)
+					// the inputs keep the marks of any sensitive values
+					val, _ := unwrapMarked(att.Expr).Value(ctx)
+					val, marks := val.Unmark()
 					mapVars = val.AsValueMap()
 
 					for k, v := range mapVars {
+						v = v.WithMarks(marks)
+
+						if d, ok := declared[k]; ok {
+							d.Source = &resources.VariableSource{Type: resources.VariableSourceInput, Name: resources.FQRNFromResource(mod).String()}
+
+							cv, err := convertVariableValue(d, v)
+							if err != nil {
+								diags = diags.Append(err)
+								continue
+							}
+
+							v = cv
+						}
+
+						if v.ContainsMarked() {
+							c.addSensitiveValues(v)
+						}
+
 						setContextVariable(mod.SubContext, k, v)
 					}
 				}
+
+				// now the inputs have been set check that the required variables have
+				// been passed by the module and validate the values
+				vars := []*resources.Variable{}
+				for _, d := range declared {
+					vars = append(vars, d)
+				}
+
+				slices.SortFunc(vars, func(a, b *resources.Variable) int {
+					return strings.Compare(a.Meta.Name, b.Meta.Name)
+				})
+
+				if missing := missingVariables(mod.SubContext, vars); len(missing) > 0 {
+					diags = diags.Append(createParserError(r, fmt.Sprintf(`required variables have not been set for module "%s": %s`, mod.Meta.Name, strings.Join(missing, ", "))))
+					return
+				}
+
+				for _, d := range vars {
+					for _, err := range validateVariable(mod.SubContext, d) {
+						diags = diags.Append(err)
+					}
+				}
 			})
+
+			if diags.HasErrors() {
+				return diags
+			}
 		}
 
 		// if this is an output or local we need to convert the value into
@@ -343,8 +449,11 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 		if r.Metadata().Type == resources.TypeOutput {
 			o := r.(*resources.Output)
 
+			// outputs are sensitive when marked or when the value has been set
+			// from a sensitive value
 			if !o.CtyValue.IsNull() {
 				o.Value = castVar(o.CtyValue)
+				o.Sensitive = o.Sensitive || slices.Contains(o.Meta.Sensitive, "value")
 			}
 		}
 
@@ -356,6 +465,19 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 			}
 		}
 
+		// preconditions are checked before the resource is processed
+		if lc != nil && len(lc.Preconditions) > 0 {
+			c.locks.withContextLock(ctx, func() {
+				for _, err := range checkConditions(ctx, "precondition", fmt.Sprintf(`resource "%s"`, r.Metadata().ID), lc.Preconditions) {
+					diags = diags.Append(err)
+				}
+			})
+
+			if diags.HasErrors() {
+				return diags
+			}
+		}
+
 		// if the config implements the processable interface call the resource process method
 		// and the resource is not disabled
 		//
@@ -372,6 +494,26 @@ func createCallback(c *Config, wf WalkCallback) func(v dag.Vertex) (diags dag.Di
 			}
 		}
 
+		// postconditions are checked after the resource has been processed and
+		// can refer to the resource using self
+		if process && lc != nil && len(lc.Postconditions) > 0 {
+			c.locks.withContextLock(ctx, func() {
+				selfCtx, err := selfContext(ctx, r)
+				if err != nil {
+					diags = diags.Append(createParserError(r, fmt.Sprintf(`unable to convert resource "%s" to self: %s`, r.Metadata().ID, err)))
+					return
+				}
+
+				for _, err := range checkConditions(selfCtx, "postcondition", fmt.Sprintf(`resource "%s"`, r.Metadata().ID), lc.Postconditions) {
+					diags = diags.Append(err)
+				}
+			})
+
+			if diags.HasErrors() {
+				return diags
+			}
+		}
+
 		return nil
 	}
 }
@@ -386,6 +528,16 @@ func setContextVariablesFromList(c *Config, r types.Resource, values []string, c
 	// all linked values should now have been processed as the graph
 	// will have handled them first
 	for _, value := range values {
+		// references to resources that have been expanded with count are set
+		// as a tuple containing the instances
+		if ok, err := setContextVariableFromInstances(c, r, value, ctx); ok || err != nil {
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		// get the value from the linked resource
 		l, err := c.FindRelativeResource(value, r.Metadata().Module)
 		if err != nil {
@@ -395,20 +547,9 @@ func setContextVariablesFromList(c *Config, r types.Resource, values []string, c
 		}
 
 		// can we set the context variables here?
-		var ctyRes cty.Value
-
 		// once we have found a resource convert it to a cty type and then
 		// set it on the context
-		switch l.Metadata().Type {
-		case resources.TypeLocal:
-			loc := l.(*resources.Local)
-			ctyRes = loc.CtyValue
-		case resources.TypeOutput:
-			out := l.(*resources.Output)
-			ctyRes = out.CtyValue
-		default:
-			ctyRes, err = convert.GoToCtyValue(l)
-		}
+		ctyRes, err := resourceToCtyValue(l)
 
 		if err != nil {
 			return createParserError(
@@ -418,7 +559,7 @@ func setContextVariablesFromList(c *Config, r types.Resource, values []string, c
 
 		// remove the attributes and to get a pure resource ref
 		// validate the name of the resource
-		fqrn, err := resources.ParseFQRN(value)
+		fqrn, err := c.blockTypes.ParseFQRN(value)
 		if err != nil {
 			return createParserError(r, fmt.Sprintf("error parsing resource link %s", err))
 		}
@@ -434,6 +575,115 @@ func setContextVariablesFromList(c *Config, r types.Resource, values []string, c
 	return nil
 }
 
+// resourceToCtyValue converts the resource into the value that is set in the context,
+// for locals and outputs this is the value, any sensitive attributes are marked so
+// that the sensitivity follows the value into the resources that reference it
+func resourceToCtyValue(r types.Resource) (cty.Value, error) {
+	paths := sensitivePaths(r)
+
+	switch v := r.(type) {
+	case *resources.Local:
+		if slices.Contains(paths, "value") {
+			return v.CtyValue.Mark(markSensitive), nil
+		}
+
+		return v.CtyValue, nil
+	case *resources.Output:
+		if slices.Contains(paths, "value") {
+			return v.CtyValue.Mark(markSensitive), nil
+		}
+
+		return v.CtyValue, nil
+	}
+
+	val, err := convert.GoToCtyValue(r)
+	if err != nil {
+		return val, err
+	}
+
+	return markSensitivePaths(val, paths), nil
+}
+
+// setContextVariableFromInstances sets the context variable for a reference to a resource
+// that has been expanded with count or for_each. The variable is a tuple containing an
+// element for every index or an object containing an attribute for every key. Only the
+// referenced instances are converted as the graph does not guarantee that the other
+// instances have been processed, any element that has not been referenced keeps its
+// existing value or is set to unknown.
+//
+// returns false when the reference is not to a resource that has been expanded
+func setContextVariableFromInstances(c *Config, r types.Resource, value string, ctx *hcl.EvalContext) (bool, *errors.ParserError) {
+	fqrn, err := c.blockTypes.ParseFQRN(value)
+	if err != nil {
+		return false, createParserError(r, fmt.Sprintf("error parsing resource link %s", err))
+	}
+
+	if fqrn.Type == resources.TypeModule {
+		return false, nil
+	}
+
+	all, ok := c.findInstances(fqrn.AppendParentModule(r.Metadata().Module))
+	if !ok {
+		return false, nil
+	}
+
+	referenced := *fqrn
+
+	// path of the collection in the context
+	fqrn.Index = nil
+	fqrn.Key = nil
+	fqrn.Attribute = ""
+	path := fqrn.String()
+
+	existing, _ := getContextVariableFromPath(ctx, path)
+
+	tuple := []cty.Value{}
+	object := map[string]cty.Value{}
+
+	for i, l := range all {
+		m := l.Metadata()
+
+		var v cty.Value
+		if (referenced.Index != nil || referenced.Key != nil) &&
+			(!equalPtr(referenced.Index, m.Index) || !equalPtr(referenced.Key, m.Key)) {
+			v = cty.DynamicVal
+
+			if m.Key != nil && existing.Type().IsObjectType() && existing.Type().HasAttribute(*m.Key) {
+				v = existing.GetAttr(*m.Key)
+			}
+
+			if m.Index != nil && existing.Type().IsTupleType() && existing.LengthInt() == len(all) {
+				v = existing.Index(cty.NumberIntVal(int64(i)))
+			}
+		} else {
+			v, err = resourceToCtyValue(l)
+			if err != nil {
+				return true, createParserError(
+					r,
+					fmt.Sprintf(`unable to convert reference %s to context variable: %s`, value, err))
+			}
+		}
+
+		if m.Key != nil {
+			object[*m.Key] = v
+		} else {
+			tuple = append(tuple, v)
+		}
+	}
+
+	val := cty.TupleVal(tuple)
+	if len(object) > 0 {
+		val = cty.ObjectVal(object)
+	}
+
+	err = setContextVariableFromPath(ctx, path, val)
+	if err != nil {
+		return true, createParserError(r, fmt.Sprintf(`unable to set context variable: %s`, err))
+	}
+
+	return true, nil
+}
+
 // validateLinkedResources validates the linked resources in a resource
 // linked resources are extracted from the interpolated values in the resource
 // and are expected to be in the format of "module.module1.module2.resource.container.mine.id"
@@ -442,11 +692,17 @@ func setContextVariablesFromList(c *Config, r types.Resource, values []string, c
 // it will also check if the attribute is a valid attribute of the resource
 func validateLinkedResources(c *Config, r types.Resource, values []string) error {
 	for _, value := range values {
-		fqrn, err := resources.ParseFQRN(value)
+		fqrn, err := c.blockTypes.ParseFQRN(value)
 		if err != nil {
 			return createParserError(r, fmt.Sprintf("error parsing resource link %s", err))
 		}
 
+		// references to all the instances of a resource that has been expanded
+		// with count or for_each resolve to a tuple or object
+		if _, ok := c.findInstances(fqrn.AppendParentModule(r.Metadata().Module)); ok && fqrn.Index == nil && fqrn.Key == nil {
+			continue
+		}
+
 		// get the value from the linked resource
 		l, err := c.FindRelativeResource(value, r.Metadata().Module)
 		if err != nil {
@@ -489,8 +745,8 @@ func validateLinkedResources(c *Config, r types.Resource, values []string) error
 
 			}
 
-			v := reflect.ValueOf(l)
-			t := reflect.TypeOf(l)
+			v := reflect.ValueOf(resourceValue(l))
+			t := reflect.TypeOf(resourceValue(l))
 
 			err = validateAttribute(v, t, flattened)
 			if err != nil {
@@ -645,6 +901,18 @@ func createParserError(r types.Resource, msg string) *errors.ParserError {
 	return pe
 }
 
+// createRangeError creates a ParserError at the start of the given range
+func createRangeError(rng hcl.Range, msg string) *errors.ParserError {
+	pe := &errors.ParserError{}
+	pe.Filename = rng.Filename
+	pe.Line = rng.Start.Line
+	pe.Column = rng.Start.Column
+	pe.Message = msg
+	pe.Level = errors.ParserErrorLevelError
+
+	return pe
+}
+
 func createParserWarning(r types.Resource, msg string) *errors.ParserError {
 	pe := &errors.ParserError{}
 	pe.Filename = r.Metadata().File
@@ -1,6 +1,7 @@
 package hclconfig
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -91,3 +92,35 @@ func TestDependenciesInvalidError(t *testing.T) {
 
 	require.Len(t, cfgErr.Errors, 13)
 }
+
+func TestDoYaLikeDAGAddsDependenciesOnInstancesFromJSON(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/count")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	d, err := c.ToJSON()
+	require.NoError(t, err)
+
+	nc, err := p.UnmarshalJSON(d)
+	require.NoError(t, err)
+
+	g, err := doYaLikeDAGs(nc)
+	require.NoError(t, err)
+
+	ids, err := nc.FindResource("output.worker_ids")
+	require.NoError(t, err)
+
+	s, err := g.Descendents(ids)
+	require.NoError(t, err)
+
+	// references to the resource depend on all the instances
+	for i := range 3 {
+		w, err := nc.FindResource(fmt.Sprintf("resource.container.worker[%d]", i))
+		require.NoError(t, err)
+		require.Contains(t, s.List(), w)
+	}
+}
@@ -0,0 +1,143 @@
+package hclconfig
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/test_fixtures/structs"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCreatesDataSources(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/data")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("data.network.existing")
+	require.NoError(t, err)
+
+	d := r.(*structs.ExistingNetwork)
+	require.Equal(t, "data.network.existing", d.Meta.ID)
+	require.True(t, d.Meta.Data)
+	require.Equal(t, "10.5.0.0/16", d.Subnet)
+
+	// data sources have their own namespace
+	r, err = c.FindResource("resource.network.existing")
+	require.NoError(t, err)
+
+	n := r.(*structs.Network)
+	require.False(t, n.Meta.Data)
+	require.Equal(t, "10.5.0.0/16", n.Subnet)
+	require.Contains(t, n.Meta.Links, "data.network.existing.subnet")
+
+	r, err = c.FindResource("resource.container.app")
+	require.NoError(t, err)
+	require.Equal(t, "onprem", r.(*structs.Container).Networks[0].Name)
+
+	r, err = c.FindResource("output.subnet")
+	require.NoError(t, err)
+	require.Equal(t, "10.5.0.0/16", r.(*resources.Output).Value)
+}
+
+func TestParseCallsCallbackForDataSourcesBeforeDependentResources(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/data")
+	require.NoError(t, err)
+
+	mutex := sync.Mutex{}
+	calls := []string{}
+	data := map[string]bool{}
+
+	o := DefaultOptions()
+	o.Callback = func(r types.Resource) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		calls = append(calls, r.Metadata().ID)
+		data[r.Metadata().ID] = r.Metadata().Data
+
+		return nil
+	}
+
+	p := setupParser(t, o)
+
+	_, err = p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	require.True(t, data["data.network.existing"])
+	require.False(t, data["resource.network.existing"])
+	require.Less(t, slices.Index(calls, "data.network.existing"), slices.Index(calls, "resource.network.existing"))
+	require.Less(t, slices.Index(calls, "data.network.existing"), slices.Index(calls, "resource.container.app"))
+}
+
+func TestParseReturnsErrorForUnregisteredDataSource(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+data "container" "app" {}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, "unable to create resource 'data'")
+}
+
+func TestDiffReturnsDataSources(t *testing.T) {
+	p := setupParser(t)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+resource "network" "existing" {
+  subnet = "10.5.0.0/16"
+}
+`))
+	require.NoError(t, err)
+
+	new, err := p.ParseSource("main.hcl", []byte(`
+resource "network" "existing" {
+  subnet = "10.5.0.0/16"
+}
+
+data "network" "existing" {
+  name = "onprem"
+}
+`))
+	require.NoError(t, err)
+
+	diff, err := c.Diff(new)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Added, 1)
+	require.Equal(t, "data.network.existing", diff.Added[0].Metadata().ID)
+	require.True(t, diff.Added[0].Metadata().Data)
+	require.Equal(t, []string{"resource.network.existing"}, resourceIDs(diff.Unchanged))
+}
+
+func TestUnmarshalJSONCreatesDataSources(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/data")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	d, err := c.ToJSON()
+	require.NoError(t, err)
+
+	nc, err := p.UnmarshalJSON(d)
+	require.NoError(t, err)
+
+	r, err := nc.FindResource("data.network.existing")
+	require.NoError(t, err)
+	require.IsType(t, &structs.ExistingNetwork{}, r)
+	require.True(t, r.Metadata().Data)
+
+	r, err = nc.FindResource("resource.network.existing")
+	require.NoError(t, err)
+	require.IsType(t, &structs.Network{}, r)
+}
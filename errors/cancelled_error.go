@@ -0,0 +1,31 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CancelledError is returned when the configuration is not completely
+// processed because the context was cancelled or its deadline exceeded
+type CancelledError struct {
+	// Resources contains the IDs of the resources that were not processed
+	Resources []string
+	// Err is the error returned by the context
+	Err error
+}
+
+// Error returns the reason for cancellation and the resources that were
+// not processed
+func (c *CancelledError) Error() string {
+	if len(c.Resources) == 0 {
+		return fmt.Sprintf("processing cancelled: %s", c.Err)
+	}
+
+	return fmt.Sprintf("processing cancelled: %s, the following resources were not processed: %s", c.Err, strings.Join(c.Resources, ", "))
+}
+
+// Unwrap returns the error from the context so that errors.Is can be used
+// to check for context.Canceled or context.DeadlineExceeded
+func (c *CancelledError) Unwrap() error {
+	return c.Err
+}
@@ -22,6 +22,22 @@ func (p *ConfigError) AppendError(err error) {
 	p.Errors = append(p.Errors, err)
 }
 
+// Unwrap returns the list of errors so that errors.Is and errors.As can
+// be used to inspect them
+func (p *ConfigError) Unwrap() []error {
+	return p.Errors
+}
+
+// Redact replaces any of the given sensitive values in the parser errors with
+// replacement
+func (p *ConfigError) Redact(values []string, replacement string) {
+	for _, e := range p.Errors {
+		if pe, ok := e.(*ParserError); ok {
+			pe.Redact(values, replacement)
+		}
+	}
+}
+
 // ContainsWarnings returns true if any of the errors are warnings
 func (p *ConfigError) ContainsWarnings() bool {
 	for _, e := range p.Errors {
@@ -1,6 +1,8 @@
 package errors
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -36,3 +38,29 @@ func TestErrorReturnsConcatonatedString(t *testing.T) {
 
 	require.Equal(t, "Error:\n  boom\n\n :0,0-0\n\nError:\n  bang\n\n :0,0-0\n", ce.Error())
 }
+
+func TestUnwrapReturnsErrors(t *testing.T) {
+	ce := NewConfigError()
+	ce.AppendError(&ParserError{Message: "boom"})
+	ce.AppendError(&CancelledError{Err: context.Canceled})
+
+	require.True(t, errors.Is(ce, context.Canceled))
+
+	var pe *ParserError
+	require.True(t, errors.As(ce, &pe))
+	require.Equal(t, "boom", pe.Message)
+}
+
+func TestCancelledErrorListsResources(t *testing.T) {
+	ce := &CancelledError{Err: context.DeadlineExceeded, Resources: []string{"resource.container.a", "resource.container.b"}}
+
+	require.Equal(t, "processing cancelled: context deadline exceeded, the following resources were not processed: resource.container.a, resource.container.b", ce.Error())
+}
+
+func TestMigrationErrorUnwrapsReason(t *testing.T) {
+	reason := errors.New("boom")
+	me := &MigrationError{ID: "resource.container.base", Version: 1, CurrentVersion: 3, Err: reason}
+
+	require.Equal(t, `unable to migrate resource "resource.container.base" from schema version 1 to 3: boom`, me.Error())
+	require.ErrorIs(t, me, reason)
+}
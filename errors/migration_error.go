@@ -0,0 +1,26 @@
+package errors
+
+import "fmt"
+
+// MigrationError is returned when a serialized resource can not be upgraded
+// to the current schema version of its registered type
+type MigrationError struct {
+	// ID is the id of the resource that could not be migrated
+	ID string
+	// Version is the schema version the resource was serialized with
+	Version int
+	// CurrentVersion is the schema version of the registered type
+	CurrentVersion int
+	// Err is the reason the resource could not be migrated
+	Err error
+}
+
+// Error returns the resource and the reason it could not be migrated
+func (m *MigrationError) Error() string {
+	return fmt.Sprintf(`unable to migrate resource "%s" from schema version %d to %d: %s`, m.ID, m.Version, m.CurrentVersion, m.Err)
+}
+
+// Unwrap returns the reason the resource could not be migrated
+func (m *MigrationError) Unwrap() error {
+	return m.Err
+}
@@ -21,6 +21,31 @@ type ParserError struct {
 	Details  string
 	Message  string
 	Level    string
+
+	// redacted are the sensitive values that are removed from the error
+	redacted    []string
+	replacement string
+}
+
+// Redact replaces any of the given sensitive values in the error with
+// replacement, values are also removed from the source shown by Error
+func (p *ParserError) Redact(values []string, replacement string) {
+	p.redacted = append(p.redacted, values...)
+	p.replacement = replacement
+	p.Message = redact(p.Message, values, replacement)
+	p.Details = redact(p.Details, values, replacement)
+}
+
+func redact(s string, values []string, replacement string) string {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+
+		s = strings.ReplaceAll(s, v, replacement)
+	}
+
+	return s
 }
 
 // Error pretty prints the error message as a string
@@ -69,7 +94,7 @@ func (p *ParserError) Error() string {
 	// file, _ := os.ReadFile(wordwrap.WrapString(p.Filename, 80))
 	file, _ := os.ReadFile(wordwrap.WrapString(errFile, 80))
 
-	lines := strings.Split(string(file), "\n")
+	lines := strings.Split(redact(string(file), p.redacted, p.replacement), "\n")
 
 	startLine := errLine - 3
 	if startLine < 0 {
@@ -55,3 +55,22 @@ func TestParserErrorNonErrorLineGrey(t *testing.T) {
 
 	require.Contains(t, err.Error(), "\033[2m      1 | variable")
 }
+
+func TestParserErrorRedactsSensitiveValues(t *testing.T) {
+	f, pathErr := filepath.Abs("../test_fixtures/simple/container.hcl")
+	require.NoError(t, pathErr)
+
+	err := ParserError{}
+	err.Line = 1
+	err.Column = 18
+	err.Filename = f
+	err.Message = "unable to login with password s3cr3t"
+	err.Details = "s3cr3t was rejected"
+
+	err.Redact([]string{"s3cr3t", "cpu_resources"}, "(sensitive value)")
+
+	require.Equal(t, "unable to login with password (sensitive value)", err.Message)
+	require.Equal(t, "(sensitive value) was rejected", err.Details)
+	require.NotContains(t, err.Error(), "s3cr3t")
+	require.NotContains(t, err.Error(), "cpu_resources")
+}
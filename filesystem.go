@@ -0,0 +1,398 @@
+package hclconfig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// osFS is a fs.FS that reads directly from the operating system, unlike
+// os.DirFS it is not rooted at a directory, names are passed unchanged to
+// the os package. This allows the parser to keep the absolute file paths
+// that are used by ParseFile and ParseDirectory.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// memFS is a read only fs.FS that holds files in memory, directories are
+// implied by the paths of the files that it contains.
+type memFS map[string][]byte
+
+// newMemFS creates a memFS from the given sources, file names are
+// cleaned and converted to forward slashes
+func newMemFS(sources map[string][]byte) memFS {
+	m := memFS{}
+	for name, src := range sources {
+		m[path.Clean(filepath.ToSlash(name))] = src
+	}
+
+	return m
+}
+
+func (m memFS) Open(name string) (fs.File, error) {
+	fi, err := m.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	f := &memFile{info: fi.(memFileInfo), Reader: bytes.NewReader(m[name])}
+	if fi.IsDir() {
+		f.entries, _ = m.ReadDir(name)
+	}
+
+	return f, nil
+}
+
+func (m memFS) ReadFile(name string) ([]byte, error) {
+	d, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return append([]byte{}, d...), nil
+}
+
+func (m memFS) Stat(name string) (fs.FileInfo, error) {
+	if d, ok := m[name]; ok {
+		return memFileInfo{name: path.Base(name), size: int64(len(d))}, nil
+	}
+
+	if name == "." {
+		return memFileInfo{name: name, dir: true}, nil
+	}
+
+	// any file with the name as a prefix means the name is a directory
+	prefix := name + "/"
+	for f := range m {
+		if strings.HasPrefix(f, prefix) {
+			return memFileInfo{name: path.Base(name), dir: true}, nil
+		}
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fi, err := m.Stat(name)
+	if err != nil || !fi.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	children := map[string]bool{}
+	for f := range m {
+		if !strings.HasPrefix(f, prefix) {
+			continue
+		}
+
+		// only add the first element of the remaining path
+		child, _, isDir := strings.Cut(strings.TrimPrefix(f, prefix), "/")
+		children[child] = children[child] || isDir
+	}
+
+	entries := []fs.DirEntry{}
+	for child, isDir := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(memFileInfo{name: child, dir: isDir, size: int64(len(m[prefix+child]))}))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+type memFile struct {
+	*bytes.Reader
+	info    memFileInfo
+	entries []fs.DirEntry
+}
+
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.info.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.info.name, Err: fs.ErrInvalid}
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+
+	return entries, nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi memFileInfo) IsDir() bool        { return fi.dir }
+func (fi memFileInfo) Sys() any           { return nil }
+
+func (fi memFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+// isOSFS returns true when the filesystem reads directly from the operating
+// system, paths for this filesystem use the os specific separators
+func isOSFS(fsys fs.FS) bool {
+	_, ok := fsys.(osFS)
+	return ok
+}
+
+// joinPath joins the given path elements using the separator for the given
+// filesystem, fs.FS implementations always use forward slashes
+func joinPath(fsys fs.FS, elem ...string) string {
+	if isOSFS(fsys) {
+		return filepath.Join(elem...)
+	}
+
+	return path.Join(elem...)
+}
+
+// dirPath returns the directory of the given file using the separator for
+// the given filesystem
+func dirPath(fsys fs.FS, file string) string {
+	if isOSFS(fsys) {
+		return filepath.Dir(file)
+	}
+
+	return path.Dir(file)
+}
+
+// inDir returns true when file is contained in dir or any of its sub folders
+func inDir(fsys fs.FS, file, dir string) bool {
+	sep := "/"
+	if isOSFS(fsys) {
+		sep = string(filepath.Separator)
+	}
+
+	return strings.HasPrefix(file, strings.TrimSuffix(dir, sep)+sep)
+}
+
+// resolvePath returns the location of p relative to the given config file
+// or directory. For operating system paths the result is made absolute, for
+// any other filesystem a leading / refers to the root of the filesystem.
+func resolvePath(fsys fs.FS, p, file string) string {
+	if isOSFS(fsys) {
+		return ensureAbsolute(p, file)
+	}
+
+	if strings.HasPrefix(p, "/") {
+		return path.Clean(strings.TrimPrefix(p, "/"))
+	}
+
+	return path.Join(baseDir(fsys, file), p)
+}
+
+// baseDir returns file when it is a directory, otherwise the directory
+// containing file
+func baseDir(fsys fs.FS, file string) string {
+	if s, err := fs.Stat(fsys, file); err == nil && s.IsDir() {
+		return file
+	}
+
+	return dirPath(fsys, file)
+}
+
+// matchAnyGlob returns true when name matches any of the given patterns
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchGlob returns true when name matches the glob pattern, both must use
+// forward slashes. Patterns use the syntax of path.Match, ** matches any number
+// of folders and patterns without a / are matched against the base name.
+func matchGlob(pattern, name string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchGlobParts(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+func matchGlobParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		// ** can match any number of parts including none
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// readIgnoreFile returns the patterns defined in the given ignore file,
+// a missing ignore file is not an error
+func readIgnoreFile(fsys fs.FS, file string) ([]string, error) {
+	d, err := fs.ReadFile(fsys, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ignore file %s: %s", file, err)
+	}
+
+	patterns := []string{}
+	for _, l := range strings.Split(string(d), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		patterns = append(patterns, l)
+	}
+
+	return patterns, nil
+}
+
+// parseHCLFile reads the given file from the filesystem and parses it as
+// HCL, files with a .hcl.json extension are parsed using the HCL JSON syntax,
+// failures to read the file are returned as a diagnostic
+func parseHCLFile(fsys fs.FS, file string) (*hcl.File, hcl.Diagnostics) {
+	src, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read file",
+				Detail:   fmt.Sprintf("The configuration file %q could not be read: %s", file, err),
+			},
+		}
+	}
+
+	if isJSONConfigFile(file) {
+		return hclparse.NewParser().ParseJSON(src, file)
+	}
+
+	return hclparse.NewParser().ParseHCL(src, file)
+}
+
+// isConfigFile returns true when the file contains configuration in either
+// the native HCL syntax or the HCL JSON syntax
+func isConfigFile(file string) bool {
+	return strings.HasSuffix(file, ".hcl") || isJSONConfigFile(file)
+}
+
+// isJSONConfigFile returns true when the file contains configuration in the
+// HCL JSON syntax
+func isJSONConfigFile(file string) bool {
+	return strings.HasSuffix(file, ".hcl.json")
+}
+
+// readFileLocation reads a file from the filesystem between the given locations
+func readFileLocation(fsys fs.FS, filename string, startLine, startCol, endLine, endCol int) (string, error) {
+	f, err := fsys.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", err)
+	}
+	defer f.Close()
+
+	fs := bufio.NewScanner(f)
+
+	cl := 0
+	output := ""
+
+	for fs.Scan() {
+		cl++
+		if cl >= startLine && cl <= endLine {
+			switch cl {
+			case startLine:
+				if startLine == endLine {
+					output += fs.Text()[startCol-1 : endCol-1]
+				} else {
+					output += fmt.Sprintf("%s%s", fs.Text()[startCol-1:], LineEnding)
+				}
+			case endLine:
+				output += fs.Text()[:endCol-1]
+			default:
+				output += fmt.Sprintf("%s%s", fs.Text(), LineEnding)
+			}
+		}
+	}
+
+	return output, nil
+}
@@ -0,0 +1,78 @@
+package hclconfig
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFSImplementsFS(t *testing.T) {
+	fsys := newMemFS(map[string][]byte{
+		"./main.hcl":            []byte(`resource "container" "one" {}`),
+		"modules/app/app.hcl":   []byte(`resource "container" "two" {}`),
+		"modules/app/vars.vars": []byte(`foo = "bar"`),
+	})
+
+	err := fstest.TestFS(fsys, "main.hcl", "modules/app/app.hcl", "modules/app/vars.vars")
+	require.NoError(t, err)
+}
+
+func TestResolvePathReturnsPathRelativeToFile(t *testing.T) {
+	fsys := newMemFS(map[string][]byte{
+		"config/main.hcl": []byte(``),
+	})
+
+	require.Equal(t, "config/files/a.txt", resolvePath(fsys, "./files/a.txt", "config/main.hcl"))
+	require.Equal(t, "config/files/a.txt", resolvePath(fsys, "./files/a.txt", "config"))
+	require.Equal(t, "files/a.txt", resolvePath(fsys, "/files/a.txt", "config/main.hcl"))
+}
+
+func TestMatchGlob(t *testing.T) {
+	tcs := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"*.hcl", "main.hcl", true},
+		{"*.hcl", "apps/web.hcl", true},
+		{"*.hcl", "apps/web.vars", false},
+		{"apps/*.hcl", "apps/web.hcl", true},
+		{"apps/*.hcl", "apps/web/web.hcl", false},
+		{"apps/**/*.hcl", "apps/web.hcl", true},
+		{"apps/**/*.hcl", "apps/web/web.hcl", true},
+		{"apps/**", "apps", true},
+		{"apps/**", "network/main.hcl", false},
+		{"ignored/", "ignored", true},
+		{"/ignored", "ignored", true},
+		{"**/test", "a/b/test", true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("%s %s", tc.pattern, tc.name), func(t *testing.T) {
+			require.Equal(t, tc.match, matchGlob(tc.pattern, tc.name))
+		})
+	}
+}
+
+func TestIsConfigFile(t *testing.T) {
+	tcs := []struct {
+		name   string
+		config bool
+		json   bool
+	}{
+		{"main.hcl", true, false},
+		{"main.hcl.json", true, true},
+		{"package.json", false, false},
+		{"values.vars.json", false, false},
+		{"main.vars", false, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.config, isConfigFile(tc.name))
+			require.Equal(t, tc.json, isJSONConfigFile(tc.name))
+		})
+	}
+}
@@ -2,6 +2,7 @@ package hclconfig
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -204,7 +205,7 @@ func appendParms(inType *[]reflect.Kind, params *[]function.Parameter, name stri
 	})
 }
 
-func getDefaultFunctions(filePath string) map[string]function.Function {
+func getDefaultFunctions(fsys fs.FS, filePath string) map[string]function.Function {
 	var EnvFunc = function.New(&function.Spec{
 		Params: []function.Parameter{
 			{
@@ -237,11 +238,11 @@ func getDefaultFunctions(filePath string) map[string]function.Function {
 		},
 		Type: function.StaticReturnType(cty.String),
 		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
-			// convert the file path to an absolute
-			fp := ensureAbsolute(args[0].AsString(), filePath)
+			// resolve the file path relative to the config
+			fp := resolvePath(fsys, args[0].AsString(), filePath)
 
 			// read the contents of the file
-			d, err := os.ReadFile(fp)
+			d, err := fs.ReadFile(fsys, fp)
 			if err != nil {
 				return cty.StringVal(""), err
 			}
@@ -266,11 +267,11 @@ func getDefaultFunctions(filePath string) map[string]function.Function {
 		},
 		Type: function.StaticReturnType(cty.String),
 		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
-			// convert the file path to an absolute
-			fp := ensureAbsolute(args[0].AsString(), filePath)
+			// resolve the file path relative to the config
+			fp := resolvePath(fsys, args[0].AsString(), filePath)
 
 			// read the contents of the file
-			d, err := os.ReadFile(fp)
+			d, err := fs.ReadFile(fsys, fp)
 			if err != nil {
 				return cty.StringVal(""), err
 			}
@@ -326,6 +327,10 @@ func getDefaultFunctions(filePath string) map[string]function.Function {
 				return cty.NumberIntVal(int64(len(args[0].AsString()))), nil
 			}
 
+			if len(args) == 1 && args[0].Type().IsObjectType() {
+				return cty.NumberIntVal(int64(len(args[0].Type().AttributeTypes()))), nil
+			}
+
 			return cty.NumberIntVal(0), nil
 		},
 	})
@@ -333,6 +338,11 @@ func getDefaultFunctions(filePath string) map[string]function.Function {
 	var DirFunc = function.New(&function.Spec{
 		Type: function.StaticReturnType(cty.String),
 		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			// paths in a non os filesystem can not be made absolute
+			if !isOSFS(fsys) {
+				return cty.StringVal(baseDir(fsys, filePath)), nil
+			}
+
 			s, err := filepath.Abs(filePath)
 
 			// check if filepath is already a directory
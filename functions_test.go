@@ -239,6 +239,22 @@ func TestParseProcessesDefaultFunctionsWithDirectory(t *testing.T) {
 	require.Contains(t, cont.Env["template_file"], "x = 1")
 }
 
+func TestParseProcessesDefaultFunctionsWithFS(t *testing.T) {
+	p := setupParser(t)
+
+	c, err := p.ParseFS(os.DirFS("./test_fixtures"), "functions/default.hcl")
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.default")
+	require.NoError(t, err)
+
+	cont := r.(*structs.Container)
+
+	require.Contains(t, cont.Env["file"], "container")
+	require.Equal(t, "functions", cont.Env["dir"])
+	require.Contains(t, cont.Env["template_file"], "Hello Raymond")
+}
+
 func TestParseProcessesCustomFunctions(t *testing.T) {
 	absoluteFolderPath, err := filepath.Abs("./test_fixtures/functions/custom.hcl")
 	if err != nil {
@@ -22,15 +22,33 @@ type Getter interface {
 	Get(src, destFolder string, ignoreCache bool) (string, error)
 }
 
+// ContextGetter defines an optional interface that allows a Getter to cancel
+// the download when the context used to parse the configuration is done.
+type ContextGetter interface {
+	// GetWithContext is the same as Get, the download is cancelled when
+	// the given context is done.
+	GetWithContext(ctx context.Context, src, destFolder string, ignoreCache bool) (string, error)
+}
+
+// getWithContext fetches the source using GetWithContext when the getter
+// implements ContextGetter, otherwise Get is used
+func getWithContext(ctx context.Context, g Getter, src, destFolder string, ignoreCache bool) (string, error) {
+	if cg, ok := g.(ContextGetter); ok {
+		return cg.GetWithContext(ctx, src, destFolder, ignoreCache)
+	}
+
+	return g.Get(src, destFolder, ignoreCache)
+}
+
 type GoGetter struct {
-	get func(src, dest, working string) error
+	get func(ctx context.Context, src, dest, working string) error
 }
 
 func NewGoGetter() Getter {
 	return &GoGetter{
-		get: func(src, dest, working string) error {
+		get: func(ctx context.Context, src, dest, working string) error {
 			c := &getter.Client{
-				Ctx:     context.Background(),
+				Ctx:     ctx,
 				Src:     src,
 				Dst:     dest,
 				Pwd:     working,
@@ -40,7 +58,7 @@ func NewGoGetter() Getter {
 
 			err := c.Get()
 			if err != nil {
-				return fmt.Errorf("unable to fetch files from %s: %s", src, err)
+				return fmt.Errorf("unable to fetch files from %s: %w", src, err)
 			}
 
 			return nil
@@ -49,6 +67,10 @@ func NewGoGetter() Getter {
 }
 
 func (g *GoGetter) Get(src, dest string, ignoreCache bool) (string, error) {
+	return g.GetWithContext(context.Background(), src, dest, ignoreCache)
+}
+
+func (g *GoGetter) GetWithContext(ctx context.Context, src, dest string, ignoreCache bool) (string, error) {
 	// check to see if a folder exists at the destination and exit if exists
 
 	pwd, err := os.Getwd()
@@ -72,7 +94,7 @@ func (g *GoGetter) Get(src, dest string, ignoreCache bool) (string, error) {
 		return downloadPath, nil
 	}
 
-	err = g.get(src, downloadPath, pwd)
+	err = g.get(ctx, src, downloadPath, pwd)
 
 	return downloadPath, err
 }
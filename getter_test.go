@@ -1,6 +1,7 @@
 package hclconfig
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -10,6 +11,7 @@ import (
 )
 
 type getterCall struct {
+	ctx     context.Context
 	src     string
 	dest    string
 	working string
@@ -19,8 +21,9 @@ func setupMockGetter(t *testing.T, err error) (Getter, *[]getterCall) {
 	calls := &[]getterCall{}
 
 	g := &GoGetter{
-		get: func(src, dest, working string) error {
+		get: func(ctx context.Context, src, dest, working string) error {
 			*calls = append(*calls, getterCall{
+				ctx:     ctx,
 				src:     src,
 				dest:    dest,
 				working: working,
@@ -92,6 +95,40 @@ func TestGetterReturnsErrorWhenUnableToDownload(t *testing.T) {
 	require.Len(t, *calls, 1)
 }
 
+func TestGetterWithContextPassesContext(t *testing.T) {
+	dest := t.TempDir()
+
+	g, calls := setupMockGetter(t, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := getWithContext(ctx, g, "github.com/test", dest, true)
+	require.NoError(t, err)
+
+	require.Len(t, *calls, 1)
+	require.Equal(t, ctx, (*calls)[0].ctx)
+}
+
+type getterWithoutContext struct {
+	calls []string
+}
+
+func (g *getterWithoutContext) Get(src, dest string, ignoreCache bool) (string, error) {
+	g.calls = append(g.calls, src)
+	return path.Join(dest, "module"), nil
+}
+
+func TestGetWithContextCallsGetWhenContextNotSupported(t *testing.T) {
+	g := &getterWithoutContext{}
+
+	p, err := getWithContext(context.Background(), g, "github.com/test", "/tmp", false)
+	require.NoError(t, err)
+
+	require.Equal(t, "/tmp/module", p)
+	require.Equal(t, []string{"github.com/test"}, g.calls)
+}
+
 func TestGetterFunctionalTest(t *testing.T) {
 	dest := t.TempDir()
 
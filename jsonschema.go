@@ -0,0 +1,424 @@
+package hclconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// jsonSchemaDraft is the version of JSON Schema used by Parser.JSONSchema
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// expressionRef is the definition of a string containing an HCL template, any
+// attribute can be set using a template i.e. "${variable.cpu}"
+const expressionRef = "#/definitions/expression"
+
+// blockDescriptions contains the descriptions of the built in blocks
+var blockDescriptions = map[string]string{
+	types.TypeResource:     "resources are processed in the order of their dependencies",
+	types.TypeData:         "data sources are read only resources that look up existing infrastructure",
+	resources.TypeVariable: "variables allow values in the configuration to be set when it is parsed",
+	resources.TypeOutput:   "outputs expose values from the configuration or a module",
+	resources.TypeLocal:    "locals assign a name to an expression",
+	resources.TypeModule:   "modules import the configuration from a folder or remote source",
+	resources.TypeCheck:    "checks define assertions that are evaluated once all resources have been processed",
+	typeMoved:              "moved records that a resource has been renamed or moved into a different module",
+}
+
+// JSONSchema returns a JSON Schema document that describes configuration files
+// using the HCL JSON syntax. The schema contains the registered resource types,
+// data sources, blocks registered with RegisterBlockType and the built in
+// blocks. Required attributes, nested blocks, defaults from the default tag and
+// descriptions from the description tag are included, fields tagged as computed
+// can not be set and are not included i.e.
+//
+//	Image string `hcl:"image" description:"image to run"`
+//	ID    string `hcl:"id,optional" computed:"true"`
+//
+// Attributes that are not strings also accept a string containing an
+// expression i.e. "${variable.cpu}".
+func (p *Parser) JSONSchema() ([]byte, error) {
+	g := &jsonSchemaGenerator{
+		definitions: map[string]any{
+			"expression": map[string]any{
+				"type":        "string",
+				"description": "template expression i.e. ${resource.container.app.name}",
+			},
+		},
+		seen: map[reflect.Type]bool{},
+	}
+
+	properties := map[string]any{
+		// the HCL JSON syntax ignores properties named // so they can be used for comments
+		"//": map[string]any{},
+	}
+
+	properties[types.TypeResource] = g.typedBlocks(types.TypeResource, p.resourceTypes(p.registeredTypes))
+	properties[types.TypeData] = g.typedBlocks(types.TypeData, p.registeredDataTypes)
+
+	for _, bt := range p.blockTypes.Sorted() {
+		if bt.Resource == nil {
+			continue
+		}
+
+		body := g.body(resourceType(bt.Resource))
+		if bt.Name == resources.TypeModule {
+			addMetaArguments(body, false)
+		}
+
+		g.define(bt.Name, body, blockDescription(bt.Name, bt.Resource))
+		properties[bt.Name] = labelled(bt.Labels, ref(bt.Name), blockDescriptions[bt.Name])
+	}
+
+	moved := g.block(g.body(reflect.TypeOf(movedBlock{})), true)
+	moved["description"] = blockDescriptions[typeMoved]
+	properties[typeMoved] = moved
+
+	return encodeJSON(map[string]any{
+		"$schema":              jsonSchemaDraft,
+		"title":                "hclconfig",
+		"description":          "configuration using the HCL JSON syntax",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+		"definitions":          g.definitions,
+	})
+}
+
+// resourceTypes returns the registered types that are defined using resource
+// blocks, the types of the other blocks are registered with the same name
+func (p *Parser) resourceTypes(rt types.RegisteredTypes) types.RegisteredTypes {
+	resourceTypes := types.RegisteredTypes{}
+	for name, r := range rt {
+		if _, ok := p.blockTypes[name]; ok || name == resources.TypeRoot {
+			continue
+		}
+
+		resourceTypes[name] = r
+	}
+
+	return resourceTypes
+}
+
+// resourceType returns the Go type that the body of the resource is decoded into
+func resourceType(r types.Resource) reflect.Type {
+	return reflect.TypeOf(resourceValue(r))
+}
+
+// blockDescription returns the description of a block, resources defined by a
+// schema have their own description
+func blockDescription(name string, r types.Resource) string {
+	if sr, ok := r.(*resources.SchemaResource); ok {
+		return sr.Schema().Description
+	}
+
+	return blockDescriptions[name]
+}
+
+// jsonSchemaGenerator creates the JSON Schema for the Go types of resources
+type jsonSchemaGenerator struct {
+	definitions map[string]any
+
+	// seen contains the struct types of the attributes that are being generated,
+	// used to stop recursive types
+	seen map[reflect.Type]bool
+}
+
+// define adds the schema of a block to the definitions
+func (g *jsonSchemaGenerator) define(name string, body map[string]any, description string) {
+	if description != "" {
+		body["description"] = description
+	}
+
+	g.definitions[name] = body
+}
+
+// typedBlocks returns the schema for resource or data blocks where the first
+// label is the type of the resource
+func (g *jsonSchemaGenerator) typedBlocks(keyword string, rt types.RegisteredTypes) map[string]any {
+	names := []string{}
+	for name := range rt {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	properties := map[string]any{}
+	for _, name := range names {
+		body := g.body(resourceType(rt[name]))
+		addMetaArguments(body, true)
+
+		g.define(keyword+"."+name, body, blockDescription(name, rt[name]))
+		properties[name] = labelled([]string{"name"}, ref(keyword+"."+name), "")
+	}
+
+	s := objectOrArray(map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	})
+	s["description"] = blockDescriptions[keyword]
+
+	return s
+}
+
+// body returns the schema for the body of a block that is decoded into the Go type
+func (g *jsonSchemaGenerator) body(t reflect.Type) map[string]any {
+	properties := map[string]any{"//": map[string]any{}}
+	required := []string{}
+	blocks := g.fields(t, properties, &required)
+
+	// dynamic blocks generate nested blocks
+	if blocks {
+		properties["dynamic"] = map[string]any{"type": "object"}
+	}
+
+	body := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 {
+		slices.Sort(required)
+		body["required"] = required
+	}
+
+	return body
+}
+
+// fields adds the attributes and nested blocks of the Go type to properties,
+// the fields of embedded structs i.e. types.ResourceBase are included. Returns
+// true when the type contains nested blocks.
+func (g *jsonSchemaGenerator) fields(t reflect.Type, properties map[string]any, required *[]string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	blocks := false
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		name, kind, ok := hclTag(f)
+		if !ok {
+			continue
+		}
+
+		// the meta data of a resource is set by the parser and computed
+		// attributes are set when the resource is processed
+		if f.Type == reflect.TypeOf(types.Meta{}) || f.Tag.Get("computed") == "true" {
+			continue
+		}
+
+		var s map[string]any
+		switch kind {
+		case "remain":
+			if f.Anonymous {
+				blocks = g.fields(f.Type, properties, required) || blocks
+			}
+
+			continue
+		case "label":
+			continue
+		case "block":
+			bt := f.Type
+			multiple := bt.Kind() == reflect.Slice
+			if multiple {
+				bt = bt.Elem()
+			}
+
+			if !multiple && bt.Kind() != reflect.Pointer {
+				*required = append(*required, name)
+			}
+
+			s = g.block(g.body(bt), multiple)
+			blocks = true
+		default:
+			s = g.attribute(f.Type)
+			if kind != "optional" {
+				*required = append(*required, name)
+			}
+
+			if d, ok := f.Tag.Lookup("default"); ok {
+				s["default"] = defaultValue(f.Type, d)
+			}
+		}
+
+		if d := f.Tag.Get("description"); d != "" {
+			s["description"] = d
+		}
+
+		properties[name] = s
+	}
+
+	return blocks
+}
+
+// block returns the schema for a nested block, in the HCL JSON syntax a block
+// is an object or an array of objects
+func (g *jsonSchemaGenerator) block(body map[string]any, multiple bool) map[string]any {
+	list := map[string]any{"type": "array", "items": body}
+	if !multiple {
+		list["maxItems"] = 1
+	}
+
+	return map[string]any{"anyOf": []any{body, list}}
+}
+
+// attribute returns the schema for the value of an attribute with the Go type,
+// values that are not strings can also be set using an expression
+func (g *jsonSchemaGenerator) attribute(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// cty values and expressions can be of any type
+	if t == reflect.TypeOf(cty.Value{}) || t.Kind() == reflect.Interface {
+		return map[string]any{}
+	}
+
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		s = map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		s = map[string]any{"type": "array", "items": g.attribute(t.Elem())}
+	case reflect.Map:
+		s = map[string]any{"type": "object", "additionalProperties": g.attribute(t.Elem())}
+	case reflect.Struct:
+		if g.seen[t] {
+			return map[string]any{}
+		}
+
+		g.seen[t] = true
+		properties := map[string]any{}
+		g.objectFields(t, properties)
+		delete(g.seen, t)
+
+		s = map[string]any{"type": "object", "properties": properties}
+	default:
+		return map[string]any{}
+	}
+
+	return map[string]any{"anyOf": []any{s, map[string]any{"$ref": expressionRef}}}
+}
+
+// objectFields adds the attributes of a struct that is used as the value of an
+// attribute to properties
+func (g *jsonSchemaGenerator) objectFields(t reflect.Type, properties map[string]any) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		name, kind, ok := hclTag(f)
+		if !ok || f.Type == reflect.TypeOf(types.Meta{}) {
+			continue
+		}
+
+		switch kind {
+		case "remain":
+			if f.Anonymous {
+				g.objectFields(f.Type, properties)
+			}
+		case "label":
+		default:
+			properties[name] = g.attribute(f.Type)
+		}
+	}
+}
+
+// hclTag returns the name and kind of the field from its hcl tag i.e.
+// `hcl:"network,block"`, false is returned for fields that are not decoded
+func hclTag(f reflect.StructField) (string, string, bool) {
+	tag := f.Tag.Get("hcl")
+	if tag == "" || tag == "-" {
+		return "", "", false
+	}
+
+	name, kind, _ := strings.Cut(tag, ",")
+
+	return name, kind, true
+}
+
+// defaultValue converts the value of a default tag to the type of the field,
+// defaults that can not be converted are returned as a string
+func defaultValue(t reflect.Type, d string) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.String {
+		return d
+	}
+
+	v := reflect.New(t)
+	if err := json.Unmarshal([]byte(d), v.Interface()); err != nil {
+		return d
+	}
+
+	return v.Elem().Interface()
+}
+
+// addMetaArguments adds the attributes that expand a block into multiple
+// instances to the body, the lifecycle block is added for resources
+func addMetaArguments(body map[string]any, resource bool) {
+	properties := body["properties"].(map[string]any)
+
+	properties["count"] = map[string]any{
+		"anyOf":       []any{map[string]any{"type": "integer"}, map[string]any{"$ref": expressionRef}},
+		"description": "number of instances to create",
+	}
+
+	properties["for_each"] = map[string]any{
+		"description": "map or set of strings, an instance is created for every element",
+	}
+
+	if resource {
+		g := &jsonSchemaGenerator{seen: map[reflect.Type]bool{}}
+		s := g.block(g.body(reflect.TypeOf(lifecycle{})), false)
+		s["description"] = "conditions that are checked when the resource is processed"
+
+		properties["lifecycle"] = s
+	}
+}
+
+// labelled returns the schema for a block with labels, in the HCL JSON syntax
+// every label is the name of a property of an object
+func labelled(labels []string, body map[string]any, description string) map[string]any {
+	s := objectOrArray(body)
+	for i := len(labels) - 1; i >= 0; i-- {
+		s = objectOrArray(map[string]any{
+			"type":                 "object",
+			"additionalProperties": s,
+		})
+	}
+
+	if description != "" {
+		s["description"] = description
+	}
+
+	return s
+}
+
+// objectOrArray returns a schema that accepts the object or an array of the
+// objects, the HCL JSON syntax accepts both for the labels and the body of a
+// block i.e. {"variable": [{"cpu": {}}, {"memory": {}}]}
+func objectOrArray(s map[string]any) map[string]any {
+	return map[string]any{"anyOf": []any{s, map[string]any{"type": "array", "items": s}}}
+}
+
+// ref returns a reference to a definition
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/definitions/" + name}
+}
@@ -0,0 +1,197 @@
+package hclconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func generateJSONSchema(t *testing.T, p *Parser) map[string]any {
+	d, err := p.JSONSchema()
+	require.NoError(t, err)
+
+	schema := map[string]any{}
+	err = json.Unmarshal(d, &schema)
+	require.NoError(t, err)
+
+	return schema
+}
+
+// definition returns the definition with the given name from the schema
+func definition(t *testing.T, schema map[string]any, name string) map[string]any {
+	def, ok := schema["definitions"].(map[string]any)[name].(map[string]any)
+	require.True(t, ok, "definition %s not found", name)
+
+	return def
+}
+
+// compileJSONSchema compiles the schema created by the parser, the schema is
+// validated against the draft-07 meta-schema when it is compiled
+func compileJSONSchema(t *testing.T, p *Parser) *jsonschema.Schema {
+	d, err := p.JSONSchema()
+	require.NoError(t, err)
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(d))
+	require.NoError(t, err)
+
+	c := jsonschema.NewCompiler()
+	err = c.AddResource("hclconfig.json", doc)
+	require.NoError(t, err)
+
+	schema, err := c.Compile("hclconfig.json")
+	require.NoError(t, err)
+
+	return schema
+}
+
+func validateJSONFile(t *testing.T, schema *jsonschema.Schema, file string) error {
+	d, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	return validateJSONString(t, schema, string(d))
+}
+
+func validateJSONString(t *testing.T, schema *jsonschema.Schema, d string) error {
+	value, err := jsonschema.UnmarshalJSON(strings.NewReader(d))
+	require.NoError(t, err)
+
+	return schema.Validate(value)
+}
+
+func TestJSONSchemaContainsRegisteredTypes(t *testing.T) {
+	p := setupParser(t)
+	schema := generateJSONSchema(t, p)
+
+	require.Equal(t, jsonSchemaDraft, schema["$schema"])
+
+	container := definition(t, schema, "resource.container")
+	properties := container["properties"].(map[string]any)
+
+	// defaults are set from the default tag
+	require.Equal(t, "hello world", properties["default"].(map[string]any)["default"])
+
+	// attributes from the resource base and the meta-arguments
+	require.Contains(t, properties, "depends_on")
+	require.Contains(t, properties, "disabled")
+	require.Contains(t, properties, "count")
+	require.Contains(t, properties, "for_each")
+	require.Contains(t, properties, "lifecycle")
+	require.NotContains(t, properties, "meta")
+
+	// required attributes of nested blocks
+	port := properties["port"].(map[string]any)["anyOf"].([]any)[0].(map[string]any)
+	require.Equal(t, []any{"local", "remote"}, port["required"])
+
+	// data sources and built in blocks
+	definition(t, schema, "data.network")
+
+	module := definition(t, schema, "module")
+	require.Equal(t, []any{"source"}, module["required"])
+	require.Contains(t, module["properties"], "count")
+
+	variable := definition(t, schema, "variable")
+	require.Equal(t, "description of the variable", variable["properties"].(map[string]any)["description"].(map[string]any)["description"])
+
+	for _, name := range []string{"output", "local", "check"} {
+		definition(t, schema, name)
+	}
+
+	require.NotContains(t, schema["definitions"], "resource.variable")
+	require.NotContains(t, schema["definitions"], "resource.root")
+}
+
+func TestJSONSchemaIsValidDraft07Schema(t *testing.T) {
+	d, err := setupBlockTypesParser(t).JSONSchema()
+	require.NoError(t, err)
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(d))
+	require.NoError(t, err)
+
+	meta, err := jsonschema.NewCompiler().Compile(jsonSchemaDraft)
+	require.NoError(t, err)
+
+	err = meta.Validate(doc)
+	require.NoError(t, err)
+}
+
+func TestJSONSchemaValidatesJSONConfig(t *testing.T) {
+	p := setupParser(t)
+	schema := compileJSONSchema(t, p)
+
+	err := validateJSONFile(t, schema, "./test_fixtures/json/resources.hcl.json")
+	require.NoError(t, err)
+
+	err = validateJSONFile(t, schema, "./test_fixtures/json/variables.hcl.json")
+	require.NoError(t, err)
+
+	err = validateJSONString(t, schema, `{
+  "moved": {"from": "resource.container.old", "to": "resource.container.new"},
+  "check": {"health": {"assert": [{"condition": "${true}", "error_message": "failed"}]}},
+  "resource": {"container": {"app": {"count": 2, "privileged": "${variable.privileged}", "lifecycle": {"precondition": {"condition": "${true}", "error_message": "failed"}}}}}
+}`)
+	require.NoError(t, err)
+
+	// the labels of blocks can be objects in an array
+	config := `{
+  "variable": [{"cpu": {"default": 1}}, {"memory": {"default": 2}}],
+  "resource": {"container": [{"app": {}}, {"web": [{"privileged": true}]}]}
+}`
+
+	err = validateJSONString(t, schema, config)
+	require.NoError(t, err)
+
+	_, err = p.ParseSource("main.hcl.json", []byte(config))
+	require.NoError(t, err)
+
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"unknown block", `{"container": {}}`, `additional properties 'container' not allowed`},
+		{"unknown type", `{"resource": {"database": {"app": {}}}}`, `additional properties 'database' not allowed`},
+		{"unknown attribute", `{"resource": {"container": {"app": {"image": "nginx"}}}}`, `additional properties 'image' not allowed`},
+		{"invalid type", `{"resource": {"container": {"app": {"privileged": 1}}}}`, `at '/resource/container/app/privileged': got number, want boolean`},
+		{"missing required attribute", `{"module": {"consul": {}}}`, `at '/module/consul': missing property 'source'`},
+		{"meta", `{"resource": {"container": {"app": {"meta": {}}}}}`, `additional properties 'meta' not allowed`},
+		{"single block", `{"resource": {"container": {"app": {"build": [{}, {}]}}}}`, `at '/resource/container/app/build': maxItems: got 2, want 1`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateJSONString(t, schema, tc.config)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestJSONSchemaContainsCustomTypes(t *testing.T) {
+	p := setupSchemaParser(t)
+	schema := generateJSONSchema(t, p)
+
+	person := definition(t, schema, "resource.person")
+	require.Equal(t, []any{"name"}, person["required"])
+
+	// computed attributes can not be set
+	require.NotContains(t, person["properties"], "id")
+
+	err := validateJSONString(t, compileJSONSchema(t, p), `{"resource": {"person": {"nic": {"name": "Nic", "age": "${variable.age}", "pet": [{"name": "Rocky"}]}}}}`)
+	require.NoError(t, err)
+
+	err = validateJSONString(t, compileJSONSchema(t, p), `{"resource": {"person": {"nic": {"name": "Nic", "id": "abc"}}}}`)
+	require.ErrorContains(t, err, `additional properties 'id' not allowed`)
+
+	bp := setupBlockTypesParser(t)
+	schema = generateJSONSchema(t, bp)
+
+	definition(t, schema, "provider")
+	definition(t, schema, "pipeline")
+
+	err = validateJSONString(t, compileJSONSchema(t, bp), `{"pipeline": {"build": {"app": {"container": "${resource.container.builder.meta.name}", "steps": ["test"]}}}}`)
+	require.NoError(t, err)
+}
@@ -0,0 +1,140 @@
+package hclconfig
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// lifecycleSchema defines the lifecycle block of a resource, the block is not
+// part of the resource and is removed from the body before it is decoded
+var lifecycleSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "lifecycle"},
+	},
+}
+
+// lifecycle contains the conditions that are checked when a resource is processed,
+// preconditions are checked before the resource is processed and postconditions
+// after, postconditions can refer to the processed resource using self
+type lifecycle struct {
+	Preconditions  []condition `hcl:"precondition,block" description:"conditions checked before the resource is processed"`
+	Postconditions []condition `hcl:"postcondition,block" description:"conditions checked after the resource is processed"`
+}
+
+// condition is a rule that must be true, the error message is returned when the
+// condition is false
+type condition struct {
+	Condition    hcl.Expression `hcl:"condition" description:"expression that must be true"`
+	ErrorMessage hcl.Expression `hcl:"error_message" description:"message returned when the condition is false"`
+}
+
+// supportsLifecycle returns true when the resource has been defined using a
+// resource block
+func (c *Config) supportsLifecycle(r types.Resource) bool {
+	if r.Metadata().Type == resources.TypeRoot {
+		return false
+	}
+
+	// the type of resources defined by other blocks is the keyword of the block
+	_, ok := c.blockTypes[r.Metadata().Type]
+
+	return !ok
+}
+
+// decodeLifecycle removes the lifecycle block from the body of the resource and
+// decodes the conditions, the remaining body is returned
+func decodeLifecycle(c *Config, r types.Resource, body hcl.Body) (*lifecycle, hcl.Body, error) {
+	if !c.supportsLifecycle(r) {
+		return nil, body, nil
+	}
+
+	content, remain, diags := body.PartialContent(lifecycleSchema)
+	if diags.HasErrors() {
+		return nil, body, createParserError(r, fmt.Sprintf(`unable to decode lifecycle: %s`, diags.Error()))
+	}
+
+	switch len(content.Blocks) {
+	case 0:
+		return nil, remain, nil
+	case 1:
+	default:
+		return nil, remain, createRangeError(content.Blocks[1].DefRange, "only one lifecycle block can be defined for a resource")
+	}
+
+	l := &lifecycle{}
+	if diags := gohcl.DecodeBody(content.Blocks[0].Body, nil, l); diags.HasErrors() {
+		return nil, remain, createParserError(r, fmt.Sprintf(`unable to decode lifecycle: %s`, diags.Error()))
+	}
+
+	return l, remain, nil
+}
+
+// checkConditions evaluates the conditions using the given context, an error is
+// returned at the location of every condition that is false. Conditions that can
+// not yet be determined are ignored. Subject describes what is being checked i.e.
+// resource "resource.container.app".
+func checkConditions(ctx *hcl.EvalContext, kind, subject string, conditions []condition) []*errors.ParserError {
+	errs := []*errors.ParserError{}
+
+	for _, c := range conditions {
+		rng := c.Condition.Range()
+
+		result, diags := c.Condition.Value(ctx)
+		if diags.HasErrors() {
+			errs = append(errs, createRangeError(rng, fmt.Sprintf(`unable to evaluate %s for %s: %s`, kind, subject, diags.Error())))
+			continue
+		}
+
+		// the result of a condition using a sensitive value is also sensitive
+		result, _ = result.UnmarkDeep()
+		if !result.IsKnown() {
+			continue
+		}
+
+		result, err := convert.Convert(result, cty.Bool)
+		if err != nil || result.IsNull() {
+			errs = append(errs, createRangeError(rng, fmt.Sprintf(`%s for %s must return a bool`, kind, subject)))
+			continue
+		}
+
+		if result.True() {
+			continue
+		}
+
+		msg, diags := c.ErrorMessage.Value(ctx)
+		if diags.HasErrors() || !msg.IsWhollyKnown() || msg.IsNull() || !msg.Type().Equals(cty.String) {
+			errs = append(errs, createRangeError(rng, fmt.Sprintf(`%s failed for %s, unable to evaluate error_message`, kind, subject)))
+			continue
+		}
+
+		// the error message must not expose a sensitive value
+		if msg.ContainsMarked() {
+			errs = append(errs, createRangeError(rng, fmt.Sprintf(`%s failed for %s: %s`, kind, subject, types.RedactedValue)))
+			continue
+		}
+
+		errs = append(errs, createRangeError(rng, fmt.Sprintf(`%s failed for %s: %s`, kind, subject, msg.AsString())))
+	}
+
+	return errs
+}
+
+// selfContext returns a child context where self refers to the resource
+func selfContext(ctx *hcl.EvalContext, r types.Resource) (*hcl.EvalContext, error) {
+	val, err := resourceToCtyValue(r)
+	if err != nil {
+		return nil, err
+	}
+
+	child := ctx.NewChild()
+	child.Variables = map[string]cty.Value{"self": val}
+
+	return child, nil
+}
@@ -0,0 +1,131 @@
+package hclconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/test_fixtures/structs"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksLifecycleConditions(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/lifecycle")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.app")
+	require.NoError(t, err)
+
+	// the resource references in conditions are dependencies of the resource
+	require.Contains(t, r.Metadata().Links, "resource.network.main.subnet")
+	require.Equal(t, 2000, r.(*structs.Container).Resources.CPU)
+}
+
+func TestParseSetsChecksumOfResourcesDependingOnPostconditions(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/lifecycle")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	// postconditions of resources are not checked when the checksums are
+	// calculated, the resources have not been processed
+	r, err := c.FindResource("resource.container.sidecar")
+	require.NoError(t, err)
+	require.NotEmpty(t, r.Metadata().Checksum.Parsed)
+}
+
+func TestParseReturnsErrorWhenPreconditionFails(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/lifecycle")
+	require.NoError(t, err)
+
+	called := []string{}
+
+	o := DefaultOptions()
+	o.Variables = map[string]string{"cpu": "8000"}
+	o.Callback = func(r types.Resource) error {
+		called = append(called, r.Metadata().ID)
+		return nil
+	}
+
+	p := setupParser(t, o)
+
+	_, err = p.ParseDirectory(absoluteFolderPath)
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, filepath.Join(absoluteFolderPath, "lifecycle.hcl"), pe.Filename)
+	require.Equal(t, 21, pe.Line)
+	require.Equal(t, 23, pe.Column)
+	require.Equal(t, `precondition failed for resource "resource.container.app": cpu must be less than or equal to 4000, got 8000`, pe.Message)
+
+	// the resource is not processed when a precondition fails
+	require.NotContains(t, called, "resource.container.app")
+}
+
+func TestParseReturnsErrorWhenPostconditionFails(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/lifecycle")
+	require.NoError(t, err)
+
+	called := []string{}
+
+	o := DefaultOptions()
+	o.Variables = map[string]string{"cpu": "500"}
+	o.Callback = func(r types.Resource) error {
+		called = append(called, r.Metadata().ID)
+		return nil
+	}
+
+	p := setupParser(t, o)
+
+	_, err = p.ParseDirectory(absoluteFolderPath)
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, 32, pe.Line)
+	require.Equal(t, `postcondition failed for resource "resource.container.app": the container must have at least 1000 cpu, got 500`, pe.Message)
+
+	// postconditions are checked after the callbacks
+	require.Contains(t, called, "resource.container.app")
+}
+
+func TestParseReturnsErrorWhenConditionIsNotABool(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "app" {
+  lifecycle {
+    precondition {
+      condition     = "yes"
+      error_message = "never returned"
+    }
+  }
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, `precondition for resource "resource.container.app" must return a bool`)
+}
+
+func TestParseReturnsErrorForMultipleLifecycleBlocks(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "app" {
+  lifecycle {}
+  lifecycle {}
+}
+`))
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, 4, pe.Line)
+	require.Contains(t, pe.Message, "only one lifecycle block can be defined for a resource")
+}
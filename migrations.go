@@ -0,0 +1,77 @@
+package hclconfig
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/types"
+)
+
+// schemaVersion returns the current version of the schema of the registered
+// type, types registered without a version have the version 0
+func (p *Parser) schemaVersion(typ string, data bool) int {
+	if data {
+		return p.dataTypeVersions[typ]
+	}
+
+	return p.typeVersions[typ]
+}
+
+// migrateResource upgrades the serialized resource to the current version of
+// the schema of its type one version at a time and decodes it into r
+func (p *Parser) migrateResource(r types.Resource, data map[string]any) error {
+	meta, _ := data["meta"].(map[string]any)
+	id, _ := meta["id"].(string)
+
+	// resources serialized before schema versions were added have the version 0
+	version := 0
+	if v, ok := meta["schema_version"].(float64); ok {
+		version = int(v)
+	}
+
+	current := p.schemaVersion(r.Metadata().Type, r.Metadata().Data)
+
+	migrationError := func(err error) error {
+		return &errors.MigrationError{ID: id, Version: version, CurrentVersion: current, Err: err}
+	}
+
+	if version > current {
+		return migrationError(fmt.Errorf(`the resource was serialized with a newer version of the type "%s"`, r.Metadata().Type))
+	}
+
+	if version < current {
+		m, ok := r.(types.Migratable)
+		if !ok {
+			return migrationError(fmt.Errorf(`the type "%s" does not implement Migratable`, r.Metadata().Type))
+		}
+
+		for v := version; v < current; v++ {
+			d, err := m.Migrate(v, data)
+			if err != nil {
+				return migrationError(fmt.Errorf("migration from version %d failed: %w", v, err))
+			}
+
+			data = d
+		}
+
+		meta, ok := data["meta"].(map[string]any)
+		if !ok {
+			return migrationError(fmt.Errorf("the migrated resource does not contain meta"))
+		}
+
+		// migrations do not need to update the version of the resource
+		meta["schema_version"] = current
+	}
+
+	d, err := json.Marshal(data)
+	if err != nil {
+		return migrationError(err)
+	}
+
+	if err := json.Unmarshal(d, r); err != nil {
+		return fmt.Errorf(`unable to decode resource "%s": %s`, id, err)
+	}
+
+	return nil
+}
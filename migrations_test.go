@@ -0,0 +1,97 @@
+package hclconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/test_fixtures/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMigrationsParser(t *testing.T) *Parser {
+	p := setupParser(t)
+	p.RegisterVersionedType(structs.TypeMount, structs.MountSchemaVersion, &structs.Mount{})
+
+	return p
+}
+
+func TestParseSetsSchemaVersion(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/migrations/mount.hcl")
+	require.NoError(t, err)
+
+	p := setupMigrationsParser(t)
+
+	c, err := p.ParseFile(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.mount.app")
+	require.NoError(t, err)
+	require.Equal(t, structs.MountSchemaVersion, r.Metadata().SchemaVersion)
+
+	d, err := c.ToJSON()
+	require.NoError(t, err)
+	require.Contains(t, string(d), `"schema_version": 2`)
+
+	nc, err := p.UnmarshalJSON(d)
+	require.NoError(t, err)
+
+	r, err = nc.FindResource("resource.mount.app")
+	require.NoError(t, err)
+	require.Equal(t, "./app", r.(*structs.Mount).HostPath)
+	require.Equal(t, "/app", r.(*structs.Mount).ContainerPath)
+}
+
+func TestUnmarshalJSONMigratesResources(t *testing.T) {
+	d, err := os.ReadFile("./test_fixtures/migrations/state.json")
+	require.NoError(t, err)
+
+	p := setupMigrationsParser(t)
+
+	c, _ := p.UnmarshalJSON(d)
+	require.NotNil(t, c)
+
+	// migrated from version 0
+	r, err := c.FindResource("resource.mount.app")
+	require.NoError(t, err)
+	require.Equal(t, "./app", r.(*structs.Mount).HostPath)
+	require.Equal(t, "/app", r.(*structs.Mount).ContainerPath)
+	require.Equal(t, structs.MountSchemaVersion, r.Metadata().SchemaVersion)
+
+	// migrated from version 1
+	r, err = c.FindResource("resource.mount.cache")
+	require.NoError(t, err)
+	require.Equal(t, "./cache", r.(*structs.Mount).HostPath)
+	require.Equal(t, "/cache", r.(*structs.Mount).ContainerPath)
+
+	// types without a version are not migrated
+	_, err = c.FindResource("resource.network.onprem")
+	require.NoError(t, err)
+}
+
+func TestUnmarshalJSONReportsResourcesThatCanNotBeMigrated(t *testing.T) {
+	d, err := os.ReadFile("./test_fixtures/migrations/state.json")
+	require.NoError(t, err)
+
+	p := setupMigrationsParser(t)
+	p.RegisterVersionedType("network", 1, &structs.Network{})
+
+	c, err := p.UnmarshalJSON(d)
+	require.Error(t, err)
+	require.Len(t, c.Resources, 2)
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 3)
+
+	ids := []string{}
+	for _, e := range ce.Errors {
+		me := e.(*errors.MigrationError)
+		ids = append(ids, me.ID)
+	}
+
+	require.Equal(t, []string{"resource.mount.logs", "resource.mount.tmp", "resource.network.onprem"}, ids)
+	require.Contains(t, ce.Errors[0].Error(), "migration from version 1 failed: target must be a string")
+	require.Contains(t, ce.Errors[1].Error(), `serialized with a newer version of the type "mount"`)
+	require.Contains(t, ce.Errors[2].Error(), `the type "network" does not implement Migratable`)
+}
@@ -0,0 +1,196 @@
+package hclconfig
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+)
+
+// typeMoved is the type of the block that records a resource has been renamed
+// or moved into a different module
+const typeMoved = "moved"
+
+// moved records that the resource From is now defined as the resource To, the
+// addresses are relative to the module that contains the moved block
+type moved struct {
+	From resources.FQRN
+	To   resources.FQRN
+}
+
+// movedBlock is the definition of a moved block
+type movedBlock struct {
+	From hcl.Expression `hcl:"from" description:"previous address of the resource"`
+	To   hcl.Expression `hcl:"to" description:"new address of the resource"`
+}
+
+// parseMoved decodes a moved block and adds it to the config
+func parseMoved(c *Config, b *hcl.Block) error {
+	if len(b.Labels) != 0 {
+		return createRangeError(b.TypeRange, `invalid formatting for 'moved' stanza, moved blocks do not have a name, i.e. 'moved {}'`)
+	}
+
+	mb := movedBlock{}
+	if diags := gohcl.DecodeBody(b.Body, nil, &mb); diags.HasErrors() {
+		rng := b.TypeRange
+		if diags[0].Subject != nil {
+			rng = *diags[0].Subject
+		}
+
+		return createRangeError(rng, fmt.Sprintf("unable to decode moved block: %s", diags[0].Detail))
+	}
+
+	from, err := c.movedAddress(mb.From, "from")
+	if err != nil {
+		return err
+	}
+
+	to, err := c.movedAddress(mb.To, "to")
+	if err != nil {
+		return err
+	}
+
+	if from.Type != to.Type || from.Data != to.Data {
+		return createRangeError(mb.To.Range(), fmt.Sprintf(`unable to move "%s" to "%s", the type of a resource can not be changed`, from.String(), to.String()))
+	}
+
+	if from.String() == to.String() {
+		return createRangeError(mb.To.Range(), fmt.Sprintf(`unable to move "%s", from and to must refer to different resources`, from.String()))
+	}
+
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	for _, m := range c.moved {
+		if m.From.String() == from.String() {
+			return createRangeError(mb.From.Range(), fmt.Sprintf(`"%s" has already been moved to "%s"`, from.String(), m.To.String()))
+		}
+
+		if m.To.String() == to.String() {
+			return createRangeError(mb.To.Range(), fmt.Sprintf(`"%s" has already been moved from "%s"`, to.String(), m.From.String()))
+		}
+	}
+
+	c.moved = append(c.moved, moved{From: *from, To: *to})
+
+	return nil
+}
+
+// movedAddress returns the resource referenced by the from or to attribute of
+// a moved block
+func (c *Config) movedAddress(expr hcl.Expression, name string) (*resources.FQRN, error) {
+	traversal, diags := hcl.AbsTraversalForExpr(expr)
+	if diags.HasErrors() {
+		return nil, createRangeError(expr.Range(), fmt.Sprintf(`%s must be a reference to a resource i.e. resource.container.web`, name))
+	}
+
+	ref, _ := processScopeTraversal(c.blockTypes, traversal)
+
+	fqrn, err := c.blockTypes.ParseFQRN(ref)
+	if err != nil || ref == "" {
+		return nil, createRangeError(expr.Range(), fmt.Sprintf(`%s must be a reference to a resource i.e. resource.container.web`, name))
+	}
+
+	// only resources defined by resource or data blocks can be moved
+	if _, ok := c.blockTypes[fqrn.Type]; ok {
+		return nil, createRangeError(expr.Range(), fmt.Sprintf(`%s must be a reference to a resource, "%s" can not be moved`, name, ref))
+	}
+
+	if fqrn.Attribute != "" {
+		return nil, createRangeError(expr.Range(), fmt.Sprintf(`%s must be a reference to a resource, not an attribute "%s"`, name, ref))
+	}
+
+	return fqrn, nil
+}
+
+// previousAddresses returns the addresses that the resource had before it was
+// moved, the most recent first. Moved blocks that do not specify an instance
+// apply to every instance of the resource.
+func (c *Config) previousAddresses(r types.Resource) []string {
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	addresses := []string{}
+	visited := map[string]bool{}
+
+	current := *c.blockTypes.FQRNFromResource(r)
+	for !visited[current.String()] {
+		visited[current.String()] = true
+
+		found := false
+		for _, m := range c.moved {
+			// the address without the instance selector
+			base := current
+			base.Index = nil
+			base.Key = nil
+
+			switch {
+			case m.To.String() == current.String():
+				current = m.From
+			case m.To.Index == nil && m.To.Key == nil && m.To.String() == base.String():
+				index, key := current.Index, current.Key
+				current = m.From
+				if current.Index == nil && current.Key == nil {
+					current.Index, current.Key = index, key
+				}
+			default:
+				continue
+			}
+
+			addresses = append(addresses, current.String())
+			found = true
+			break
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	return addresses
+}
+
+// movedChecksum returns a checksum of the resource that does not include the
+// properties that change when a resource is moved, i.e. the name and location
+func movedChecksum(r types.Resource) string {
+	d, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+
+	props := map[string]any{}
+	if err := json.Unmarshal(d, &props); err != nil {
+		return ""
+	}
+
+	if meta, ok := props["meta"].(map[string]any); ok {
+		for _, k := range []string{"id", "name", "module", "index", "key", "file", "line", "column", "checksum", "links"} {
+			delete(meta, k)
+		}
+	}
+
+	d, _ = json.Marshal(props)
+
+	return HashString(string(d))
+}
+
+// findMovedResource returns the resource in this config that the resource in
+// the new config o has been moved from, resources that are still defined in
+// the new config are not returned
+func (c *Config) findMovedResource(o *Config, r types.Resource) (types.Resource, bool) {
+	for _, addr := range o.previousAddresses(r) {
+		// the previous resource has been replaced by a new resource
+		if _, err := o.FindResource(addr); err == nil {
+			return nil, false
+		}
+
+		if cr, err := c.findResource(addr); err == nil {
+			return cr, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,241 @@
+package hclconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/stretchr/testify/require"
+)
+
+func resourceIDs(rs []types.Resource) []string {
+	ids := []string{}
+	for _, r := range rs {
+		ids = append(ids, r.Metadata().ID)
+	}
+
+	return ids
+}
+
+func TestDiffReturnsMovedResources(t *testing.T) {
+	before, err := filepath.Abs("./test_fixtures/moved/before")
+	require.NoError(t, err)
+
+	after, err := filepath.Abs("./test_fixtures/moved/after")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(before)
+	require.NoError(t, err)
+
+	new, err := p.ParseDirectory(after)
+	require.NoError(t, err)
+
+	diff, err := c.Diff(new)
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []string{"resource.container.frontend", "module.data.resource.container.db"}, resourceIDs(diff.Moved))
+	require.Equal(t, map[string]string{
+		"resource.container.frontend":       "resource.container.web",
+		"module.data.resource.container.db": "resource.container.db",
+	}, diff.MovedFrom)
+
+	require.Equal(t, []string{"module.data"}, resourceIDs(diff.Added))
+	require.Empty(t, diff.Removed)
+	require.NotContains(t, resourceIDs(diff.ParseUpdated), "resource.container.frontend")
+	require.NotContains(t, resourceIDs(diff.Unchanged), "resource.container.web")
+}
+
+func TestDiffReturnsMovedResourcesThatHaveChangedAsUpdated(t *testing.T) {
+	p := setupParser(t)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "web" {
+  command = ["nginx"]
+}
+`))
+	require.NoError(t, err)
+
+	new, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "frontend" {
+  command = ["httpd"]
+}
+
+moved {
+  from = resource.container.web
+  to   = resource.container.frontend
+}
+`))
+	require.NoError(t, err)
+
+	diff, err := c.Diff(new)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"resource.container.frontend"}, resourceIDs(diff.ParseUpdated))
+	require.Equal(t, map[string]string{"resource.container.frontend": "resource.container.web"}, diff.MovedFrom)
+	require.Empty(t, diff.Moved)
+	require.Empty(t, diff.Added)
+	require.Empty(t, diff.Removed)
+	require.Empty(t, diff.Unchanged)
+}
+
+func TestDiffFollowsChainedMovesForInstances(t *testing.T) {
+	p := setupParser(t)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "web" {
+  count = 2
+}
+`))
+	require.NoError(t, err)
+
+	new, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "app" {
+  count = 2
+}
+
+moved {
+  from = resource.container.web
+  to   = resource.container.frontend
+}
+
+moved {
+  from = resource.container.frontend
+  to   = resource.container.app
+}
+`))
+	require.NoError(t, err)
+
+	diff, err := c.Diff(new)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Moved, 2)
+	require.Equal(t, map[string]string{
+		"resource.container.app[0]": "resource.container.web[0]",
+		"resource.container.app[1]": "resource.container.web[1]",
+	}, diff.MovedFrom)
+	require.Empty(t, diff.Added)
+	require.Empty(t, diff.Removed)
+}
+
+func TestDiffDoesNotMoveResourcesThatStillExist(t *testing.T) {
+	p := setupParser(t)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "web" {}
+`))
+	require.NoError(t, err)
+
+	new, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "web" {}
+
+resource "container" "frontend" {}
+
+moved {
+  from = resource.container.web
+  to   = resource.container.frontend
+}
+`))
+	require.NoError(t, err)
+
+	diff, err := c.Diff(new)
+	require.NoError(t, err)
+
+	require.Empty(t, diff.Moved)
+	require.Empty(t, diff.MovedFrom)
+	require.Equal(t, []string{"resource.container.frontend"}, resourceIDs(diff.Added))
+	require.Equal(t, []string{"resource.container.web"}, resourceIDs(diff.Unchanged))
+}
+
+func TestParseReturnsErrorForInvalidMovedBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		line    int
+		message string
+	}{
+		{
+			name: "type changed",
+			src: `
+moved {
+  from = resource.container.web
+  to   = resource.network.web
+}`,
+			line:    4,
+			message: `unable to move "resource.container.web" to "resource.network.web", the type of a resource can not be changed`,
+		},
+		{
+			name: "resource to data source",
+			src: `
+moved {
+  from = resource.network.web
+  to   = data.network.web
+}`,
+			line:    4,
+			message: `unable to move "resource.network.web" to "data.network.web", the type of a resource can not be changed`,
+		},
+		{
+			name: "attribute",
+			src: `
+moved {
+  from = resource.container.web.meta.name
+  to   = resource.container.frontend
+}`,
+			line:    3,
+			message: `from must be a reference to a resource, not an attribute "resource.container.web.meta.name"`,
+		},
+		{
+			name: "not a resource",
+			src: `
+moved {
+  from = resource.container.web
+  to   = output.web
+}`,
+			line:    4,
+			message: `to must be a reference to a resource, "output.web" can not be moved`,
+		},
+		{
+			name: "not a reference",
+			src: `
+moved {
+  from = "resource.container.web"
+  to   = resource.container.frontend
+}`,
+			line:    3,
+			message: `from must be a reference to a resource i.e. resource.container.web`,
+		},
+		{
+			name: "moved twice",
+			src: `
+moved {
+  from = resource.container.web
+  to   = resource.container.frontend
+}
+
+moved {
+  from = resource.container.web
+  to   = resource.container.app
+}`,
+			line:    8,
+			message: `"resource.container.web" has already been moved to "resource.container.frontend"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := setupParser(t)
+
+			_, err := p.ParseSource("main.hcl", []byte(tc.src))
+			require.Error(t, err)
+
+			ce := err.(*errors.ConfigError)
+			require.Len(t, ce.Errors, 1)
+
+			pe := ce.Errors[0].(*errors.ParserError)
+			require.Equal(t, tc.line, pe.Line)
+			require.Equal(t, tc.message, pe.Message)
+		})
+	}
+}
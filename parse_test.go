@@ -1,12 +1,14 @@
 package hclconfig
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/hashicorp/hcl/v2"
@@ -37,6 +39,7 @@ func setupParser(t *testing.T, options ...*ParserOptions) *Parser {
 	p.RegisterType("network", &structs.Network{})
 	p.RegisterType("template", &structs.Template{})
 	p.RegisterType(structs.TypeParseError, &structs.ParseError{})
+	p.RegisterDataType("network", &structs.ExistingNetwork{})
 
 	return p
 }
@@ -91,6 +94,771 @@ func TestParseFileProcessesResources(t *testing.T) {
 	require.NotNil(t, r)
 }
 
+func TestParseFSProcessesResources(t *testing.T) {
+	p := setupParser(t)
+
+	c, err := p.ParseFS(os.DirFS("./test_fixtures"), "simple")
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.consul")
+	require.NoError(t, err)
+
+	// check the file is relative to the root of the filesystem
+	cont := r.(*structs.Container)
+	require.Equal(t, "simple/container.hcl", cont.Metadata().File)
+	require.NotEmpty(t, cont.Metadata().Checksum.Parsed)
+
+	// check variable has been interpolated using the override value
+	require.Equal(t, 1024, cont.Resources.CPU)
+	require.Equal(t, "10.6.0.200", cont.Networks[0].IPAddress)
+}
+
+func TestParseFSResolvesLocalModules(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/main.hcl": &fstest.MapFile{Data: []byte(`
+module "app" {
+  source = "../modules/app"
+}
+
+output "image" {
+  value = module.app.output.image
+}
+`)},
+		"modules/app/app.hcl": &fstest.MapFile{Data: []byte(`
+resource "container" "app" {
+  command = ["nginx"]
+}
+
+output "image" {
+  value = resource.container.app.command[0]
+}
+`)},
+	}
+
+	p := setupParser(t)
+
+	c, err := p.ParseFS(fsys, "config/main.hcl")
+	require.NoError(t, err)
+
+	r, err := c.FindResource("module.app.resource.container.app")
+	require.NoError(t, err)
+	require.Equal(t, "modules/app/app.hcl", r.Metadata().File)
+
+	r, err = c.FindResource("output.image")
+	require.NoError(t, err)
+	require.Equal(t, "nginx", r.(*resources.Output).Value)
+}
+
+func TestParseFSReturnsConfigErrorWhenRootDoesNotExist(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseFS(fstest.MapFS{}, "notexist.hcl")
+	require.IsType(t, err, &errors.ConfigError{})
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 1)
+}
+
+func TestParseSourceProcessesResources(t *testing.T) {
+	src := `
+variable "cpu" {
+  default = 512
+}
+
+resource "container" "app" {
+  command = ["nginx"]
+
+  resources {
+    cpu = variable.cpu
+  }
+}
+`
+
+	p := setupParser(t)
+
+	c, err := p.ParseSource("config/app.hcl", []byte(src))
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.app")
+	require.NoError(t, err)
+
+	cont := r.(*structs.Container)
+	require.Equal(t, "config/app.hcl", cont.Metadata().File)
+	require.Equal(t, 512, cont.Resources.CPU)
+}
+
+func TestParseSourceReturnsErrorWithFilename(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("app.hcl", []byte(`resource "container" "app" {`))
+	require.IsType(t, err, &errors.ConfigError{})
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 1)
+	require.Equal(t, "app.hcl", ce.Errors[0].(*errors.ParserError).Filename)
+}
+
+func TestParseSourcesProcessesResources(t *testing.T) {
+	sources := map[string][]byte{
+		"main.hcl": []byte(`
+variable "cpu" {
+  default = 512
+}
+
+module "app" {
+  source = "./modules/app"
+
+  variables = {
+    cpu = variable.cpu
+  }
+}
+`),
+		"override.vars": []byte(`cpu = 1024`),
+		"modules/app/app.hcl": []byte(`
+variable "cpu" {
+  default = 0
+}
+
+resource "container" "app" {
+  resources {
+    cpu = variable.cpu
+  }
+}
+`),
+	}
+
+	p := setupParser(t)
+
+	c, err := p.ParseSources(sources)
+	require.NoError(t, err)
+
+	// resources in sub folders are only parsed as part of the module
+	_, err = c.FindResource("resource.container.app")
+	require.Error(t, err)
+
+	r, err := c.FindResource("module.app.resource.container.app")
+	require.NoError(t, err)
+
+	cont := r.(*structs.Container)
+	require.Equal(t, "modules/app/app.hcl", cont.Metadata().File)
+	require.Equal(t, 1024, cont.Resources.CPU)
+}
+
+func TestParseDirectoryCanRunConcurrently(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/simple")
+	require.NoError(t, err)
+
+	// use a mix of shared and independent parsers
+	shared := setupParser(t)
+	parsers := []*Parser{}
+
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			parsers = append(parsers, setupParser(t))
+			continue
+		}
+
+		parsers = append(parsers, shared)
+	}
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, len(parsers))
+
+	for _, p := range parsers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			c, err := p.ParseDirectory(absoluteFolderPath)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			r, err := c.FindResource("resource.container.consul")
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if r.(*structs.Container).Resources.CPU != 1024 {
+				errs <- fmt.Errorf("expected cpu to be 1024, got %d", r.(*structs.Container).Resources.CPU)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestParseDirectoryWithContextReturnsErrorWhenCancelled(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/simple")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = p.ParseDirectoryWithContext(ctx, absoluteFolderPath)
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParseFileWithContextCallsContextCallback(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/simple/container.hcl")
+	require.NoError(t, err)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	calls := []string{}
+	callSync := sync.Mutex{}
+
+	o := DefaultOptions()
+	o.ContextCallback = func(ctx context.Context, r types.Resource) error {
+		require.Equal(t, "value", ctx.Value(key{}))
+
+		callSync.Lock()
+		calls = append(calls, r.Metadata().ID)
+		callSync.Unlock()
+
+		return nil
+	}
+
+	p := setupParser(t, o)
+
+	_, err = p.ParseFileWithContext(ctx, absoluteFolderPath)
+	require.NoError(t, err)
+
+	require.Contains(t, calls, "resource.container.base")
+}
+
+func TestParseFileWithContextStopsProcessingWhenCancelled(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/simple/container.hcl")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	o := DefaultOptions()
+	o.ContextCallback = func(ctx context.Context, r types.Resource) error {
+		if r.Metadata().ID == "resource.network.onprem" {
+			cancel()
+		}
+
+		return nil
+	}
+
+	p := setupParser(t, o)
+
+	_, err = p.ParseFileWithContext(ctx, absoluteFolderPath)
+	require.ErrorIs(t, err, context.Canceled)
+
+	ce := &errors.CancelledError{}
+	require.ErrorAs(t, err, &ce)
+	require.Contains(t, ce.Resources, "resource.container.base")
+}
+
+func TestParseDirectoryStopsAtFirstErrorByDefault(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/recover")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.Nil(t, c)
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 1)
+}
+
+func TestParseDirectoryContinueOnErrorReturnsAllErrors(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/recover")
+	require.NoError(t, err)
+
+	o := DefaultOptions()
+	o.ContinueOnError = true
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.Error(t, err)
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 4)
+
+	files := []string{}
+	for _, e := range ce.Errors {
+		files = append(files, filepath.Base(e.(*errors.ParserError).Filename))
+	}
+
+	require.ElementsMatch(t, []string{"resources.hcl", "resources.hcl", "resources.hcl", "syntax.hcl"}, files)
+
+	// the partial config contains the resources that could be parsed
+	require.NotNil(t, c)
+
+	_, err = c.FindResource("resource.network.main")
+	require.NoError(t, err)
+
+	_, err = c.FindResource("resource.container.web")
+	require.NoError(t, err)
+
+	_, err = c.FindResource("resource.parse_error.mine")
+	require.NoError(t, err)
+}
+
+func TestParseSourcesContinueOnErrorReportsVariablesFileErrorsOnce(t *testing.T) {
+	sources := map[string][]byte{
+		"network.hcl": []byte(`
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+`),
+		"container.hcl": []byte(`
+resource "container" "web" {
+  command = ["nginx"]
+}
+`),
+		"invalid.vars": []byte(`cpu = `),
+	}
+
+	o := DefaultOptions()
+	o.ContinueOnError = true
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseSources(sources)
+	require.Error(t, err)
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 1)
+	require.Equal(t, "invalid.vars", ce.Errors[0].(*errors.ParserError).Filename)
+
+	_, err = c.FindResource("resource.container.web")
+	require.NoError(t, err)
+}
+
+func TestParseExpandsCountIntoInstances(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/count")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	for i := range 3 {
+		r, err := c.FindResource(fmt.Sprintf("resource.container.worker[%d]", i))
+		require.NoError(t, err)
+
+		cont := r.(*structs.Container)
+		require.Equal(t, i, *cont.Meta.Index)
+		require.Equal(t, fmt.Sprintf("resource.container.worker[%d]", i), cont.Meta.ID)
+		require.Equal(t, []string{"worker", fmt.Sprint(i)}, cont.Command)
+		require.Equal(t, "main", cont.Networks[0].Name)
+	}
+
+	_, err = c.FindResource("resource.container.worker[3]")
+	require.Error(t, err)
+
+	// references to a single instance only depend on that instance
+	r, err := c.FindResource("resource.container.first")
+	require.NoError(t, err)
+	require.Equal(t, []string{"worker", "0"}, r.(*structs.Container).Command)
+	require.Equal(t, []string{"resource.container.worker[0].command"}, r.Metadata().Links)
+
+	// references to the resource resolve to all the instances
+	r, err = c.FindResource("output.worker_ids")
+	require.NoError(t, err)
+	require.Equal(t, []any{"resource.container.worker[0]", "resource.container.worker[1]", "resource.container.worker[2]"}, r.(*resources.Output).Value)
+
+	r, err = c.FindResource("output.worker_count")
+	require.NoError(t, err)
+	require.Equal(t, float64(3), r.(*resources.Output).Value)
+
+	r, err = c.FindResource("output.none_count")
+	require.NoError(t, err)
+	require.Equal(t, float64(0), r.(*resources.Output).Value)
+}
+
+func TestParseExpandsCountInModules(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/count")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	// count uses the value of the module variable
+	_, err = c.FindResource("module.workers.resource.container.worker[1]")
+	require.NoError(t, err)
+
+	_, err = c.FindResource("module.workers.resource.container.worker[2]")
+	require.Error(t, err)
+
+	r, err := c.FindResource("module.workers.output.commands")
+	require.NoError(t, err)
+	require.Equal(t, []any{[]any{"worker", "0"}, []any{"worker", "1"}}, r.(*resources.Output).Value)
+}
+
+func TestDiffOnlyContainsChangedCountInstances(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/count")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	o := DefaultOptions()
+	o.Variables = map[string]string{"workers": "4"}
+	p = setupParser(t, o)
+
+	new, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	diff, err := c.Diff(new)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Added, 1)
+	require.Equal(t, "resource.container.worker[3]", diff.Added[0].Metadata().ID)
+	require.Empty(t, diff.Removed)
+}
+
+func TestParseCountReturnsErrorWhenReferencingResources(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" "worker" {
+  count = len(resource.network.main.subnet)
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, "count can only reference variables and literals")
+}
+
+func TestParseExpandsForEachIntoInstances(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/for_each")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	for k, v := range map[string]string{"api": "api:v1", "web": "web:v2"} {
+		r, err := c.FindResource(fmt.Sprintf(`resource.container.app["%s"]`, k))
+		require.NoError(t, err)
+
+		cont := r.(*structs.Container)
+		require.Equal(t, k, *cont.Meta.Key)
+		require.Nil(t, cont.Meta.Index)
+		require.Equal(t, fmt.Sprintf(`resource.container.app["%s"]`, k), cont.Meta.ID)
+		require.Equal(t, []string{"app", k}, cont.Command)
+		require.Equal(t, v, cont.Env["IMAGE"])
+		require.Equal(t, "main", cont.Networks[0].Name)
+	}
+
+	// sets use the element as the key and the value
+	r, err := c.FindResource(`resource.container.sidecar["logs"]`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"logs", "logs"}, r.(*structs.Container).Command)
+
+	// references to a single instance only depend on that instance
+	r, err = c.FindResource("resource.container.proxy")
+	require.NoError(t, err)
+	require.Equal(t, []string{"app", "api"}, r.(*structs.Container).Command)
+	require.Equal(t, []string{`resource.container.app["api"].command`}, r.Metadata().Links)
+
+	// references to the resource resolve to an object containing all the instances
+	r, err = c.FindResource("output.app_ids")
+	require.NoError(t, err)
+	require.Equal(t, []any{`resource.container.app["api"]`, `resource.container.app["web"]`}, r.(*resources.Output).Value)
+
+	r, err = c.FindResource("output.sidecar_count")
+	require.NoError(t, err)
+	require.Equal(t, float64(2), r.(*resources.Output).Value)
+}
+
+func TestParseExpandsForEachInModules(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/for_each")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	api, err := c.FindResource(`module.env["api"]`)
+	require.NoError(t, err)
+	require.Equal(t, "api", *api.Metadata().Key)
+
+	web, err := c.FindResource(`module.env["web"]`)
+	require.NoError(t, err)
+
+	// every instance of the module has its own context
+	require.NotSame(t, api.(*resources.Module).SubContext, web.(*resources.Module).SubContext)
+
+	r, err := c.FindResource(`module.env["web"].resource.container.app`)
+	require.NoError(t, err)
+	require.Equal(t, "web:v2", r.(*structs.Container).Env["IMAGE"])
+
+	rs, err := c.FindModuleResources(`module.env["api"]`, false)
+	require.NoError(t, err)
+	require.Len(t, rs, 3)
+
+	r, err = c.FindResource("output.api_image")
+	require.NoError(t, err)
+	require.Equal(t, "api:v1", r.(*resources.Output).Value)
+}
+
+func TestDiffOnlyContainsChangedForEachInstances(t *testing.T) {
+	source := `
+resource "container" "app" {
+  for_each = %s
+
+  command = ["app", each.key]
+}
+`
+
+	p := setupParser(t)
+
+	c, err := p.ParseSource("main.hcl", []byte(fmt.Sprintf(source, `["api", "web"]`)))
+	require.NoError(t, err)
+
+	p = setupParser(t)
+
+	new, err := p.ParseSource("main.hcl", []byte(fmt.Sprintf(source, `["admin", "api", "web"]`)))
+	require.NoError(t, err)
+
+	diff, err := c.Diff(new)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Added, 1)
+	require.Equal(t, `resource.container.app["admin"]`, diff.Added[0].Metadata().ID)
+	require.Empty(t, diff.Removed)
+	require.Empty(t, diff.ParseUpdated)
+}
+
+func TestParseForEachReturnsErrorWhenSetWithCount(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "app" {
+  count    = 2
+  for_each = ["api"]
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, "count and for_each can not be set on the same block")
+}
+
+func TestParseForEachReturnsErrorForInvalidKeys(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "app" {
+  for_each = ["my.app"]
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, `invalid for_each key "my.app"`)
+}
+
+func TestParseExpandsDynamicBlocks(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/dynamic")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.app")
+	require.NoError(t, err)
+
+	// references inside the dynamic block are dependencies of the resource
+	require.ElementsMatch(t, []string{"resource.network.main", "resource.network.backend"}, r.Metadata().Links)
+
+	cont := r.(*structs.Container)
+	require.Len(t, cont.Networks, 2)
+	require.Equal(t, "main", cont.Networks[0].Name)
+	require.Equal(t, "10.0.0.200", cont.Networks[0].IPAddress)
+	require.Equal(t, "backend", cont.Networks[1].Name)
+	require.Equal(t, "10.1.0.200", cont.Networks[1].IPAddress)
+
+	require.Equal(t, []structs.Port{{Local: 8080, Remote: 80}, {Local: 8443, Remote: 443}}, cont.Ports)
+
+	// static blocks are still decoded
+	require.Len(t, cont.Volumes, 1)
+}
+
+func TestParseDynamicBlockReturnsErrorWithoutContent(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+resource "container" "app" {
+  dynamic "network" {
+    for_each = ["main"]
+  }
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, "content block")
+}
+
+func TestParseDirectoryRecursiveProcessesResources(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/recursive")
+	require.NoError(t, err)
+
+	o := DefaultOptions()
+	o.Recursive = true
+	o.IgnoreFile = ".hclignore"
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.web")
+	require.NoError(t, err)
+
+	// check the references and variables are resolved across folders
+	cont := r.(*structs.Container)
+	require.Equal(t, filepath.Join(absoluteFolderPath, "apps", "web.hcl"), cont.Metadata().File)
+	require.Equal(t, "main", cont.Networks[0].Name)
+	require.Equal(t, 1024, cont.Resources.CPU)
+
+	// check files in the ignore file are not parsed
+	_, err = c.FindResource("resource.container.skipped")
+	require.Error(t, err)
+
+	// check that module sources are only parsed by the module
+	_, err = c.FindResource("resource.container.module_app")
+	require.Error(t, err)
+
+	_, err = c.FindResource("module.app.resource.container.module_app")
+	require.NoError(t, err)
+}
+
+func TestParseDirectoryRecursiveAppliesIncludeAndExclude(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/recursive")
+	require.NoError(t, err)
+
+	o := DefaultOptions()
+	o.Recursive = true
+	o.Include = []string{"*.hcl"}
+	o.Exclude = []string{"ignored", "apps/**", "**/*.vars"}
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	_, err = c.FindResource("resource.network.main")
+	require.NoError(t, err)
+
+	_, err = c.FindResource("resource.container.web")
+	require.Error(t, err)
+}
+
+func TestParseDirectoryDoesNotRecurseByDefault(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/recursive")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	_, err = c.FindResource("module.app.resource.container.module_app")
+	require.NoError(t, err)
+
+	_, err = c.FindResource("resource.network.main")
+	require.Error(t, err)
+}
+
+func TestParseDirectoryProcessesJSONAndNativeSyntax(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/json")
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	r, err := c.FindResource("resource.container.consul")
+	require.NoError(t, err)
+
+	cont := r.(*structs.Container)
+	require.Equal(t, filepath.Join(absoluteFolderPath, "resources.hcl.json"), cont.Metadata().File)
+	require.Contains(t, cont.Metadata().Links, "resource.network.onprem.meta.name")
+	require.Equal(t, "onprem", cont.Networks[0].Name)
+	require.Equal(t, 512, cont.Resources.CPU)
+
+	// check references from native files to JSON files are resolved
+	r, err = c.FindResource("resource.container.native")
+	require.NoError(t, err)
+
+	native := r.(*structs.Container)
+	require.Equal(t, "consul:consul", native.Command[0])
+	require.Equal(t, 512, native.Resources.CPU)
+
+	r, err = c.FindResource("module.consul.resource.container.consul")
+	require.NoError(t, err)
+	require.Equal(t, 512, r.(*structs.Container).Resources.CPU)
+
+	r, err = c.FindResource("output.module_cpu")
+	require.NoError(t, err)
+	require.Equal(t, "cpu of the module container", r.(*resources.Output).Description)
+	require.Equal(t, float64(512), r.(*resources.Output).Value)
+
+	r, err = c.FindResource("variable.cpu")
+	require.NoError(t, err)
+	require.NotEmpty(t, r.Metadata().Checksum.Parsed)
+}
+
+func TestParseSourceReturnsErrorForInvalidJSON(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("app.hcl.json", []byte(`{"resource": {"container": "app"}}`))
+	require.IsType(t, err, &errors.ConfigError{})
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 1)
+	require.Equal(t, "app.hcl.json", ce.Errors[0].(*errors.ParserError).Filename)
+}
+
+func TestParseSourceReturnsWarningForUnknownJSONBlock(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("app.hcl.json", []byte(`{
+  "resource": {"container": {"app": {"command": ["nginx"]}}},
+  "provider": {"docker": {}}
+}`))
+	require.IsType(t, err, &errors.ConfigError{})
+
+	ce := err.(*errors.ConfigError)
+	require.False(t, ce.ContainsErrors())
+	require.Len(t, ce.Errors, 1)
+
+	pe := ce.Errors[0].(*errors.ParserError)
+	require.Equal(t, errors.ParserErrorLevelWarning, pe.Level)
+	require.Equal(t, 3, pe.Line)
+	require.Contains(t, pe.Message, "unable to process stanza 'provider'")
+}
+
 func TestParseFileCallsParseFunction(t *testing.T) {
 	absoluteFolderPath, err := filepath.Abs("./test_fixtures/simple/container.hcl")
 	if err != nil {
@@ -279,6 +1047,498 @@ func TestLoadsVariablesFilesOverridingVariableDefaults(t *testing.T) {
 	require.Equal(t, 1024, cont.Resources.CPU)
 }
 
+func TestParseRecordsTheSourceOfVariableValues(t *testing.T) {
+	o := DefaultOptions()
+	o.Variables = map[string]string{"region": "eu-west-1"}
+
+	t.Setenv("HCL_VAR_zone", "b")
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseSources(map[string][]byte{
+		"main.hcl": []byte(`
+variable "size" {
+  default = "small"
+}
+
+variable "image" {
+  default = "nginx"
+}
+
+variable "region" {
+  default = "us-east-1"
+}
+
+variable "zone" {
+  default = "a"
+}
+`),
+		"override.vars": []byte(`image = "nginx:1.27"`),
+	})
+	require.NoError(t, err)
+
+	sources := map[string]string{
+		"size":   "default",
+		"image":  "file:override.vars",
+		"zone":   "env:HCL_VAR_zone",
+		"region": "option",
+	}
+
+	for k, v := range sources {
+		r, err := c.FindResource("variable." + k)
+		require.NoError(t, err)
+		require.Equal(t, v, r.(*resources.Variable).Source.String(), k)
+	}
+}
+
+func TestParseRecordsModuleInputsAsTheSourceOfVariableValues(t *testing.T) {
+	p := setupParser(t)
+
+	c, err := p.ParseSources(map[string][]byte{
+		"main.hcl": []byte(`
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+module "app" {
+  source = "./modules/app"
+
+  variables = {
+    subnet = resource.network.main.subnet
+  }
+}
+`),
+		"modules/app/app.hcl": []byte(`
+variable "subnet" {
+  default = "192.168.0.0/24"
+}
+
+variable "name" {
+  default = "app"
+}
+`),
+	})
+	require.NoError(t, err)
+
+	r, err := c.FindResource("module.app.variable.subnet")
+	require.NoError(t, err)
+	require.Equal(t, "input:module.app", r.(*resources.Variable).Source.String())
+
+	r, err = c.FindResource("module.app.variable.name")
+	require.NoError(t, err)
+	require.Equal(t, "default", r.(*resources.Variable).Source.String())
+}
+
+func TestParseAppliesVariablesInConfiguredPrecedence(t *testing.T) {
+	o := DefaultOptions()
+	o.Variables = map[string]string{"image": "nginx:option"}
+	o.VariablePrecedence = []string{"option", "env", "file"}
+
+	t.Setenv("HCL_VAR_image", "nginx:env")
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseSources(map[string][]byte{
+		"main.hcl": []byte(`
+variable "image" {
+  default = "nginx"
+}
+
+output "image" {
+  value = variable.image
+}
+`),
+		"override.vars": []byte(`image = "nginx:file"`),
+	})
+	require.NoError(t, err)
+
+	r, err := c.FindResource("variable.image")
+	require.NoError(t, err)
+	require.Equal(t, "file:override.vars", r.(*resources.Variable).Source.String())
+
+	r, err = c.FindResource("output.image")
+	require.NoError(t, err)
+	require.Equal(t, "nginx:file", r.(*resources.Output).Value)
+}
+
+func TestParseReturnsErrorForInvalidVariablePrecedence(t *testing.T) {
+	o := DefaultOptions()
+	o.VariablePrecedence = []string{"file", "cli"}
+
+	p := setupParser(t, o)
+
+	_, err := p.ParseSource("main.hcl", []byte(`variable "image" {}`))
+	require.ErrorContains(t, err, `unknown source "cli"`)
+}
+
+func TestParseConvertsVariablesToDeclaredType(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/variables")
+	require.NoError(t, err)
+
+	os.Setenv("HCL_VAR_replicas", "3")
+	t.Cleanup(func() {
+		os.Unsetenv("HCL_VAR_replicas")
+	})
+
+	o := DefaultOptions()
+	o.Variables = map[string]string{"version": "0123"}
+	p := setupParser(t, o)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	outputs := map[string]any{
+		"output.ports":   []any{float64(80), float64(443)},
+		"output.service": map[string]any{"name": "api", "port": float64(8080)},
+		// overrides for string variables are not converted to numbers
+		"output.version":  "0123",
+		"output.replicas": float64(3),
+		// module inputs are converted when parsed and when the graph is walked
+		"module.typed.output.replicas": float64(2),
+		"output.module_port":           float64(9090),
+	}
+
+	for k, v := range outputs {
+		r, err := c.FindResource(k)
+		require.NoError(t, err)
+		require.Equal(t, v, r.(*resources.Output).Value, k)
+	}
+
+	r, err := c.FindResource("resource.container.app")
+	require.NoError(t, err)
+	require.Equal(t, []string{"api", "8080"}, r.(*structs.Container).Command)
+}
+
+func TestParseReturnsErrorWhenVariableDoesNotMatchType(t *testing.T) {
+	o := DefaultOptions()
+	o.Variables = map[string]string{"replicas": "many"}
+	p := setupParser(t, o)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "replicas" {
+  type    = number
+  default = 1
+}
+`))
+	require.Error(t, err)
+
+	ce := err.(*errors.ConfigError)
+	pe := ce.Errors[0].(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Contains(t, pe.Message, `invalid value for variable "replicas", expected number`)
+}
+
+func TestParseParsesVariableOverridesAsHCLLiterals(t *testing.T) {
+	o := DefaultOptions()
+	o.Variables = map[string]string{
+		"tags":    `{ env = "prod", team = "platform" }`,
+		"ratio":   "0.75",
+		"nothing": "null",
+		"name":    "nginx:1.27",
+		"version": "1.0",
+	}
+
+	t.Setenv("HCL_VAR_ports", "[80, 443]")
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+variable "ports" {
+  type    = list(number)
+  default = []
+}
+
+variable "tags" {
+  type    = map(string)
+  default = {}
+}
+
+variable "ratio" {
+  default = 1
+}
+
+variable "nothing" {
+  default = "something"
+}
+
+variable "name" {
+  default = ""
+}
+
+variable "version" {
+  type    = string
+  default = ""
+}
+
+output "ports" {
+  value = variable.ports
+}
+
+output "tags" {
+  value = variable.tags
+}
+
+output "ratio" {
+  value = variable.ratio
+}
+
+output "nothing" {
+  value = variable.nothing
+}
+
+output "name" {
+  value = variable.name
+}
+
+output "version" {
+  value = variable.version
+}
+`))
+	require.NoError(t, err)
+
+	outputs := map[string]any{}
+	for _, n := range []string{"ports", "tags", "ratio", "nothing", "name", "version"} {
+		r, err := c.FindResource("output." + n)
+		require.NoError(t, err)
+
+		outputs[n] = r.(*resources.Output).Value
+	}
+
+	require.Equal(t, []any{float64(80), float64(443)}, outputs["ports"])
+	require.Equal(t, map[string]any{"env": "prod", "team": "platform"}, outputs["tags"])
+	require.Equal(t, 0.75, outputs["ratio"])
+	require.Nil(t, outputs["nothing"])
+
+	// values that are not valid literals are strings
+	require.Equal(t, "nginx:1.27", outputs["name"])
+
+	// variables declared as strings are not parsed
+	require.Equal(t, "1.0", outputs["version"])
+}
+
+func TestParseKeepsUntypedVariableOverridesThatAreNotNumbersAsStrings(t *testing.T) {
+	t.Setenv("HCL_VAR_ver", "1.10")
+	t.Setenv("HCL_VAR_count", "3")
+
+	p := setupParser(t)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+variable "ver" {
+  default = "1.0"
+}
+
+variable "count" {
+  default = 1
+}
+
+output "ver" {
+  value = variable.ver
+}
+
+output "count" {
+  value = variable.count
+}
+`))
+	require.NoError(t, err)
+
+	r, err := c.FindResource("output.ver")
+	require.NoError(t, err)
+	require.Equal(t, "1.10", r.(*resources.Output).Value)
+
+	r, err = c.FindResource("output.count")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, r.(*resources.Output).Value)
+}
+
+func TestParseReturnsErrorWhenVariableOverrideIsNotALiteral(t *testing.T) {
+	t.Setenv("HCL_VAR_ports", "[80, http]")
+
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "ports" {
+  type    = list(number)
+  default = []
+}
+`))
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Contains(t, pe.Message, `invalid value for variable "ports", expected list(number): the value set by env:HCL_VAR_ports is not a valid HCL literal`)
+}
+
+func TestParseReturnsErrorWhenVariableOverrideDoesNotMatchType(t *testing.T) {
+	t.Setenv("HCL_VAR_tags", `{ env = ["prod"] }`)
+
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "tags" {
+  type    = map(string)
+  default = {}
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, `invalid value for variable "tags", expected map(string)`)
+	require.ErrorContains(t, err, `value set by env:HCL_VAR_tags`)
+}
+
+func TestParseReturnsErrorForInvalidVariableType(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "replicas" {
+  type    = integer
+  default = 1
+}
+`))
+	require.Error(t, err)
+	require.ErrorContains(t, err, `invalid type for variable "replicas"`)
+}
+
+func TestParseReturnsAllFailingVariableValidations(t *testing.T) {
+	o := DefaultOptions()
+	o.Variables = map[string]string{"name": "x"}
+	p := setupParser(t, o)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "name" {
+  default = "api"
+
+  validation {
+    condition     = len(variable.name) > 2
+    error_message = "name must be longer than 2 characters, got ${variable.name}"
+  }
+
+  validation {
+    condition     = variable.name != "x"
+    error_message = "name can not be x"
+  }
+
+  validation {
+    condition     = variable.name != "api"
+    error_message = "name can not be api"
+  }
+}
+`))
+	require.Error(t, err)
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 2)
+
+	pe := ce.Errors[0].(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Equal(t, `invalid value for variable "name": name must be longer than 2 characters, got x`, pe.Message)
+
+	pe = ce.Errors[1].(*errors.ParserError)
+	require.Equal(t, `invalid value for variable "name": name can not be x`, pe.Message)
+}
+
+func TestParseValidatesModuleVariablesWithInputs(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSources(map[string][]byte{
+		"main.hcl": []byte(`
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+module "app" {
+  source = "./modules/app"
+
+  variables = {
+    subnet = resource.network.main.subnet
+  }
+}
+`),
+		"modules/app/app.hcl": []byte(`
+variable "subnet" {
+  default = "192.168.0.0/24"
+
+  validation {
+    condition     = variable.subnet == "192.168.0.0/24"
+    error_message = "subnet must be 192.168.0.0/24"
+  }
+}
+`),
+	})
+	require.Error(t, err)
+	require.ErrorContains(t, err, `invalid value for variable "subnet": subnet must be 192.168.0.0/24`)
+}
+
+func TestParseReturnsErrorListingRequiredVariables(t *testing.T) {
+	o := DefaultOptions()
+	o.Variables = map[string]string{"region": "eu-west-1"}
+	p := setupParser(t, o)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "region" {}
+
+variable "username" {}
+
+variable "password" {
+  description = "password for the database"
+}
+
+variable "port" {
+  default = 5432
+}
+`))
+	require.Error(t, err)
+
+	ce := err.(*errors.ConfigError)
+	require.Len(t, ce.Errors, 1)
+
+	pe := ce.Errors[0].(*errors.ParserError)
+	require.Equal(t, 6, pe.Line)
+	require.Equal(t, `required variables have not been set: "password", "username"`, pe.Message)
+}
+
+func TestParseSetsRequiredVariables(t *testing.T) {
+	o := DefaultOptions()
+	o.Variables = map[string]string{"username": "admin"}
+	p := setupParser(t, o)
+
+	c, err := p.ParseSource("main.hcl", []byte(`
+variable "username" {}
+
+output "username" {
+  value = variable.username
+}
+`))
+	require.NoError(t, err)
+
+	r, err := c.FindResource("output.username")
+	require.NoError(t, err)
+	require.Equal(t, "admin", r.(*resources.Output).Value)
+}
+
+func TestParseReturnsErrorWhenModuleDoesNotSetRequiredVariables(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSources(map[string][]byte{
+		"main.hcl": []byte(`
+module "db" {
+  source = "./modules/db"
+
+  variables = {
+    username = "admin"
+  }
+}
+`),
+		"modules/db/db.hcl": []byte(`
+variable "username" {}
+
+variable "password" {}
+
+variable "database" {}
+`),
+	})
+	require.Error(t, err)
+	require.ErrorContains(t, err, `required variables have not been set for module "db": "database", "password"`)
+}
+
 func TestResourceReferencesInExpressionsAreEvaluated(t *testing.T) {
 	absoluteFolderPath, err := filepath.Abs("./test_fixtures/interpolation/interpolation.hcl")
 	if err != nil {
@@ -1,19 +1,22 @@
 package hclconfig
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
-	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/jumppad-labs/hclconfig/errors"
 	"github.com/jumppad-labs/hclconfig/registry"
@@ -23,8 +26,6 @@ import (
 	"github.com/zclconf/go-cty/cty/function"
 )
 
-var rootContext *hcl.EvalContext
-
 type ResourceTypeNotExistError struct {
 	Type string
 	File string
@@ -41,6 +42,12 @@ type ParserOptions struct {
 	VariablesFiles []string
 	// environment variable prefix
 	VariableEnvPrefix string
+	// VariablePrecedence is the order that the sources of variable values are
+	// applied, later sources override earlier ones. Valid sources are "file",
+	// "env" and "option", sources that are not in the list are not applied.
+	// Defaults are always applied first and the inputs of a module last.
+	// When empty the order is file, env, option.
+	VariablePrecedence []string
 	// location of any downloaded modules
 	ModuleCache string
 	// default registry to use when fetching modules
@@ -54,6 +61,34 @@ type ParserOptions struct {
 	// you to set the dependent properties of resource 'b' before resource 'a'
 	// consumes them.
 	Callback WalkCallback
+	// ContextCallback is the same as Callback but is also passed the context
+	// given to the parse method, when both are set Callback is executed first.
+	ContextCallback WalkContextCallback
+
+	// Recursive causes ParseDirectory and ParseFS to parse all the files in the
+	// directory tree, sub folders that are the source of a local module are only
+	// parsed by the module. By default only the files in the given directory
+	// are parsed.
+	Recursive bool
+	// Include is a list of glob patterns relative to the parsed directory, when
+	// set only files that match one of the patterns are parsed. Patterns support
+	// the syntax of path.Match, ** matches any number of folders, patterns that do
+	// not contain a / are matched against the name of the file.
+	Include []string
+	// Exclude is a list of glob patterns relative to the parsed directory, files
+	// and folders that match any of the patterns are not parsed.
+	Exclude []string
+	// IgnoreFile is the name of a file in the root of the parsed directory that
+	// contains additional Exclude patterns, one pattern per line. Blank lines and
+	// lines starting with # are ignored.
+	IgnoreFile string
+
+	// ContinueOnError causes the parser to continue parsing all files and blocks
+	// when an error is found. Every diagnostic and every resource Parse error is
+	// returned in the ConfigError along with the partially parsed Config, the
+	// resources in a partial Config are not processed. By default parsing stops
+	// at the first error and a nil Config is returned.
+	ContinueOnError bool
 
 	// PrimativesOnly will parse a structure including modules:
 	// * registered types for the resources are not loaded, all resources are
@@ -92,7 +127,12 @@ func DefaultOptions() *ParserOptions {
 type Parser struct {
 	options             ParserOptions
 	registeredTypes     types.RegisteredTypes
+	registeredDataTypes types.RegisteredTypes
+	typeVersions        map[string]int
+	dataTypeVersions    map[string]int
+	blockTypes          resources.BlockTypes
 	registeredFunctions map[string]function.Function
+	variablesDecoders   map[string]VariablesDecoder
 }
 
 // NewParser creates a new parser with the given options
@@ -103,13 +143,57 @@ func NewParser(options *ParserOptions) *Parser {
 		o = DefaultOptions()
 	}
 
-	return &Parser{options: *o, registeredTypes: resources.DefaultResources(), registeredFunctions: map[string]function.Function{}}
+	return &Parser{
+		options:             *o,
+		registeredTypes:     resources.DefaultResources(),
+		registeredDataTypes: types.RegisteredTypes{},
+		typeVersions:        map[string]int{},
+		dataTypeVersions:    map[string]int{},
+		blockTypes:          resources.DefaultBlockTypes(),
+		registeredFunctions: map[string]function.Function{},
+		variablesDecoders:   defaultVariablesDecoders(),
+	}
 }
 
 // RegisterType type registers a struct that implements Resource with the given name
-// the parser uses this list to convert hcl defined resources into concrete types
+// the parser uses this list to convert hcl defined resources into concrete types.
+// Blocks registered with RegisterBlockType take precedence, the type is not
+// registered when the name is the keyword of a registered block.
 func (p *Parser) RegisterType(name string, resource types.Resource) {
+	if _, ok := p.customBlockType(name); ok {
+		return
+	}
+
+	p.registeredTypes[name] = resource
+	delete(p.typeVersions, name)
+}
+
+// RegisterVersionedType is the same as RegisterType but also sets the version of
+// the schema of the type, the version should be increased when a field of the
+// type is renamed or restructured. Resources serialized with an older version
+// are upgraded by UnmarshalJSON using the Migratable interface.
+func (p *Parser) RegisterVersionedType(name string, version int, resource types.Resource) {
+	if _, ok := p.customBlockType(name); ok {
+		return
+	}
+
 	p.registeredTypes[name] = resource
+	p.typeVersions[name] = version
+}
+
+// RegisterDataType registers a struct that implements Resource as a data source
+// with the given name, data sources are defined using data blocks and have their
+// own namespace so a data source can have the same name as a resource type
+func (p *Parser) RegisterDataType(name string, resource types.Resource) {
+	p.registeredDataTypes[name] = resource
+	delete(p.dataTypeVersions, name)
+}
+
+// RegisterVersionedDataType is the same as RegisterDataType but also sets the
+// version of the schema of the data source, see RegisterVersionedType
+func (p *Parser) RegisterVersionedDataType(name string, version int, resource types.Resource) {
+	p.registeredDataTypes[name] = resource
+	p.dataTypeVersions[name] = version
 }
 
 // RegisterFunction type registers a custom interpolation function
@@ -126,71 +210,138 @@ func (p *Parser) RegisterFunction(name string, f any) error {
 	return nil
 }
 
-// ParseDirectory parses all resources in the given file
+// RegisterVariablesDecoder registers a decoder for variables files with the given
+// extension i.e. ".toml", replacing any existing decoder for the extension.
+// Variables files in a parsed directory are found using the extension .vars or
+// .vars followed by the extension of a registered decoder i.e. prod.vars.toml
+func (p *Parser) RegisterVariablesDecoder(extension string, d VariablesDecoder) {
+	p.variablesDecoders[extension] = d
+}
+
+// ParseFile parses all resources in the given file
 // error can be cast to *ConfigError to get a list of errors
 func (p *Parser) ParseFile(file string) (*Config, error) {
-	c := NewConfig()
-	rootContext = buildContext(file, p.registeredFunctions)
+	return p.ParseFileWithContext(context.Background(), file)
+}
 
-	ce := errors.NewConfigError()
+// ParseFileWithContext is the same as ParseFile, the context is used to
+// cancel the download of modules and the processing of resources.
+// error can be cast to *ConfigError to get a list of errors
+func (p *Parser) ParseFileWithContext(ctx context.Context, file string) (*Config, error) {
+	return p.parseFS(ctx, osFS{}, file, false)
+}
 
-	err := p.parseFile(rootContext, file, c, p.options.Variables, p.options.VariablesFiles)
-	if err != nil {
-		for _, e := range err {
-			ce.AppendError(e)
-		}
+// ParseDirectory parses all resource and variable files in the given directory
+// note: this method does not recurse into sub folders unless the Recursive
+// option is set
+// error can be cast to *ConfigError to get a list of errors
+func (p *Parser) ParseDirectory(dir string) (*Config, error) {
+	return p.ParseDirectoryWithContext(context.Background(), dir)
+}
 
-		fmt.Println("parse error, skipping call Parse")
-		return nil, ce
-	}
+// ParseDirectoryWithContext is the same as ParseDirectory, the context is used
+// to cancel the download of modules and the processing of resources.
+// error can be cast to *ConfigError to get a list of errors
+func (p *Parser) ParseDirectoryWithContext(ctx context.Context, dir string) (*Config, error) {
+	return p.parseFS(ctx, osFS{}, dir, true)
+}
 
-	for _, rt := range c.Resources {
-		// call the resources Parse function if set
-		// if the config implements the processable interface call the resource process method
-		if p, ok := rt.(types.Parsable); ok {
-			err := p.Parse(c)
-			if err != nil {
-				de := &errors.ParserError{}
-				de.Line = rt.Metadata().Line
-				de.Column = rt.Metadata().Column
-				de.Filename = rt.Metadata().File
-				de.Level = errors.ParserErrorLevelError
-				de.Message = fmt.Sprintf(`error parsing resource "%s" %s`, resources.FQRNFromResource(rt).String(), err)
+// ParseFS parses the configuration at root in the given filesystem, root can
+// either be a single file or a directory. All files, including those read
+// by functions like file() and local modules, are read from fsys. Modules that
+// are fetched from a remote source are read from the module cache on disk.
+// error can be cast to *ConfigError to get a list of errors
+func (p *Parser) ParseFS(fsys fs.FS, root string) (*Config, error) {
+	return p.ParseFSWithContext(context.Background(), fsys, root)
+}
 
-				ce.AppendError(de)
-			}
-		}
-	}
+// ParseFSWithContext is the same as ParseFS, the context is used to cancel
+// the download of modules and the processing of resources.
+// error can be cast to *ConfigError to get a list of errors
+func (p *Parser) ParseFSWithContext(ctx context.Context, fsys fs.FS, root string) (*Config, error) {
+	fi, err := fs.Stat(fsys, root)
+	if err != nil {
+		ce := errors.NewConfigError()
+		ce.AppendError(fmt.Errorf("unable to read %s: %s", root, err))
 
-	if len(ce.Errors) > 0 {
 		return nil, ce
 	}
 
-	// do not walk the dag when we are only dealing with primatives
-	if p.options.PrimativesOnly {
-		return c, nil
-	}
+	return p.parseFS(ctx, fsys, root, fi.IsDir())
+}
 
-	// process the files and resolve dependency
-	return c, p.process(c)
+// ParseSource parses the given source as if it had been read from filename,
+// filename is used for any diagnostics and the resource meta data. Any files
+// referenced by the configuration such as modules or those read with file()
+// must be parsed with ParseSources or ParseFS.
+// error can be cast to *ConfigError to get a list of errors
+func (p *Parser) ParseSource(filename string, src []byte) (*Config, error) {
+	fsys := newMemFS(map[string][]byte{filename: src})
+
+	return p.parseFS(context.Background(), fsys, path.Clean(filepath.ToSlash(filename)), false)
 }
 
-// ParseDirectory parses all resource and variable files in the given directory
-// note: this method does not recurse into sub folders
+// ParseSources parses a collection of sources keyed by filename, the sources
+// are treated as a directory. Sources in the root of the collection are parsed
+// in the same way as ParseDirectory, sources in sub folders are available to
+// local modules and functions like file().
 // error can be cast to *ConfigError to get a list of errors
-func (p *Parser) ParseDirectory(dir string) (*Config, error) {
-	c := NewConfig()
-	rootContext = buildContext(dir, p.registeredFunctions)
+func (p *Parser) ParseSources(sources map[string][]byte) (*Config, error) {
+	return p.parseFS(context.Background(), newMemFS(sources), ".", true)
+}
+
+func (p *Parser) parseFS(goCtx context.Context, fsys fs.FS, root string, isDir bool) (*Config, error) {
+	c := p.newConfig()
+
+	conf, err := p.parseConfig(goCtx, fsys, root, isDir, c)
+
+	// sensitive values must not be exposed by the errors
+	c.redactErrors(err)
+
+	return conf, err
+}
+
+// parseConfig parses the file or directory at root into the given config
+func (p *Parser) parseConfig(goCtx context.Context, fsys fs.FS, root string, isDir bool, c *Config) (*Config, error) {
+	ctx := buildContext(fsys, root, p.registeredFunctions)
 
 	ce := errors.NewConfigError()
 
-	err := p.parseDirectory(rootContext, dir, c)
+	if err := validateVariablePrecedence(p.options.VariablePrecedence); err != nil {
+		ce.AppendError(err)
+		return nil, ce
+	}
+
+	var err []error
+	if isDir {
+		err = p.parseDirectory(goCtx, fsys, ctx, root, c, true, nil)
+	} else {
+		err = p.parseFiles(goCtx, fsys, ctx, []string{root}, c, p.options.Variables, p.options.VariablesFiles, nil)
+	}
+
 	if err != nil {
 		for _, e := range err {
 			ce.AppendError(e)
 		}
 
-		return nil, ce
+		// ensure that the cancellation can be detected with errors.Is
+		if goCtx.Err() != nil {
+			ce.AppendError(&errors.CancelledError{Err: goCtx.Err()})
+		}
+
+		if !p.options.ContinueOnError {
+			return nil, ce
+		}
+	}
+
+	// variables are checked once all the sources for their values have been
+	// applied, variables in modules are checked when the module is walked
+	if err := checkRequiredVariables(ctx, c, ""); err != nil {
+		ce.AppendError(err)
+	}
+
+	for _, e := range validateVariables(ctx, c, "") {
+		ce.AppendError(e)
 	}
 
 	for _, rt := range c.Resources {
@@ -204,7 +355,7 @@ func (p *Parser) ParseDirectory(dir string) (*Config, error) {
 				de.Line = rt.Metadata().Line
 				de.Column = rt.Metadata().Column
 				de.Filename = rt.Metadata().File
-				de.Message = fmt.Sprintf(`error parsing resource "%s" %s`, resources.FQRNFromResource(rt).String(), err)
+				de.Message = fmt.Sprintf(`error parsing resource "%s" %s`, c.blockTypes.FQRNFromResource(rt).String(), err)
 
 				ce.AppendError(de)
 			}
@@ -212,6 +363,10 @@ func (p *Parser) ParseDirectory(dir string) (*Config, error) {
 	}
 
 	if len(ce.Errors) > 0 {
+		if p.options.ContinueOnError {
+			return c, ce
+		}
+
 		return nil, ce
 	}
 
@@ -221,13 +376,16 @@ func (p *Parser) ParseDirectory(dir string) (*Config, error) {
 	}
 
 	// process the files and resolve dependency
-	return c, p.process(c)
+	return c, p.process(goCtx, c)
 }
 
 // UnmarshalJSON parses a JSON string from a serialized Config and returns a
-// valid Config.
+// valid Config. Resources that were serialized with an older schema version of
+// their type are migrated to the current version, resources that can not be
+// migrated are reported as a *errors.MigrationError in the returned ConfigError
+// along with a Config containing the other resources.
 func (p *Parser) UnmarshalJSON(d []byte) (*Config, error) {
-	conf := NewConfig()
+	conf := p.newConfig()
 
 	var objMap map[string]*json.RawMessage
 	err := json.Unmarshal(d, &objMap)
@@ -241,6 +399,8 @@ func (p *Parser) UnmarshalJSON(d []byte) (*Config, error) {
 		return nil, err
 	}
 
+	ce := errors.NewConfigError()
+
 	for _, m := range rawMessagesForResources {
 		mm := map[string]any{}
 		err := json.Unmarshal(*m, &mm)
@@ -249,27 +409,42 @@ func (p *Parser) UnmarshalJSON(d []byte) (*Config, error) {
 		}
 
 		meta := mm["meta"].(map[string]any)
+		data, _ := meta["data"].(bool)
 
-		r, err := p.registeredTypes.CreateResource(meta["type"].(string), meta["name"].(string))
+		registeredTypes := p.registeredTypes
+		if data {
+			registeredTypes = p.registeredDataTypes
+		}
+
+		r, err := registeredTypes.CreateResource(meta["type"].(string), meta["name"].(string))
 		if err != nil {
 			return nil, err
 		}
 
-		resData, _ := json.Marshal(mm)
+		if err := p.migrateResource(r, mm); err != nil {
+			ce.AppendError(err)
+			continue
+		}
 
-		json.Unmarshal(resData, r)
 		conf.addResource(r, nil, nil)
 	}
 
+	if len(ce.Errors) > 0 {
+		return conf, ce
+	}
+
 	return conf, nil
 }
 
-// internal method
-func (p *Parser) parseDirectory(ctx *hcl.EvalContext, dir string, c *Config) []error {
+// parseDirectory parses the files in the given directory, when root is true
+// the directory is the one requested by the user and the Recursive, Include,
+// Exclude and IgnoreFile options apply. inputs are the values for the module
+// variables when the directory is the source of a module
+func (p *Parser) parseDirectory(goCtx context.Context, fsys fs.FS, ctx *hcl.EvalContext, dir string, c *Config, root bool, inputs map[string]cty.Value) []error {
 
 	// get all files in a directory
-	path, err := os.Stat(dir)
-	if os.IsNotExist(err) {
+	path, err := fs.Stat(fsys, dir)
+	if err != nil {
 		return []error{fmt.Errorf("directory %s does not exist", dir)}
 	}
 
@@ -277,7 +452,7 @@ func (p *Parser) parseDirectory(ctx *hcl.EvalContext, dir string, c *Config) []e
 		return []error{fmt.Errorf("%s is not a directory", dir)}
 	}
 
-	files, err := os.ReadDir(dir)
+	files, err := p.listFiles(fsys, dir, root)
 	if err != nil {
 		return []error{fmt.Errorf("unable to list files in directory %s, error: %s", dir, err)}
 	}
@@ -285,270 +460,904 @@ func (p *Parser) parseDirectory(ctx *hcl.EvalContext, dir string, c *Config) []e
 	variablesFiles := p.options.VariablesFiles
 
 	// first process vars files
-	for _, f := range files {
-		fn := filepath.Join(dir, f.Name())
+	for _, fn := range files {
+		if p.isVariablesFile(fn) {
+			// add to the collection
+			variablesFiles = append(variablesFiles, fn)
+		}
+	}
+
+	configFiles := []string{}
+	for _, fn := range files {
+		if isConfigFile(fn) {
+			configFiles = append(configFiles, fn)
+		}
+	}
+
+	return p.parseFiles(goCtx, fsys, ctx, configFiles, c, p.options.Variables, variablesFiles, inputs)
+}
+
+// listFiles returns the files in the given directory that should be parsed,
+// files are returned in lexical order
+func (p *Parser) listFiles(fsys fs.FS, dir string, root bool) ([]string, error) {
+	files := []string{}
+
+	// module directories are always read flat
+	if !root {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
 
-		if !f.IsDir() {
-			if strings.HasSuffix(fn, ".vars") {
-				// add to the collection
-				variablesFiles = append(variablesFiles, fn)
+		for _, f := range entries {
+			if !f.IsDir() {
+				files = append(files, joinPath(fsys, dir, f.Name()))
 			}
 		}
+
+		return files, nil
 	}
 
-	for _, f := range files {
-		fn := filepath.Join(dir, f.Name())
+	exclude := p.options.Exclude
+	if p.options.IgnoreFile != "" {
+		patterns, err := readIgnoreFile(fsys, joinPath(fsys, dir, p.options.IgnoreFile))
+		if err != nil {
+			return nil, err
+		}
 
-		if !f.IsDir() {
-			if strings.HasSuffix(fn, ".hcl") {
-				err := p.parseFile(ctx, fn, c, p.options.Variables, variablesFiles)
-				if err != nil {
-					return err
-				}
+		exclude = append(append([]string{}, exclude...), patterns...)
+	}
+
+	prefix := path.Clean(dir) + "/"
+
+	err := fs.WalkDir(fsys, dir, func(fn string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fn == dir {
+			return nil
+		}
+
+		// patterns are matched against the path relative to the root
+		rel := strings.TrimPrefix(fn, prefix)
+
+		if d.IsDir() {
+			if !p.options.Recursive || matchAnyGlob(exclude, rel) {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if matchAnyGlob(exclude, rel) {
+			return nil
+		}
+
+		if len(p.options.Include) > 0 && !matchAnyGlob(p.options.Include, rel) {
+			return nil
+		}
+
+		if isOSFS(fsys) {
+			fn = filepath.Clean(fn)
+		}
+
+		files = append(files, fn)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.options.Recursive {
+		return files, nil
+	}
+
+	// files in the source folder of a local module are parsed by the module
+	moduleDirs := p.localModuleDirs(fsys, files)
+
+	filtered := []string{}
+	for _, fn := range files {
+		inModule := false
+		for _, md := range moduleDirs {
+			if inDir(fsys, fn, md) {
+				inModule = true
+				break
 			}
 		}
+
+		if !inModule {
+			filtered = append(filtered, fn)
+		}
 	}
 
-	return nil
+	return filtered, nil
 }
 
-// parseFile loads variables and resources from the given file
-func (p *Parser) parseFile(
+// localModuleDirs returns the folders that are used as the source for local
+// modules defined in the given files, only sources that are literal values
+// can be resolved.
+func (p *Parser) localModuleDirs(fsys fs.FS, files []string) []string {
+	dirs := []string{}
+
+	for _, fn := range files {
+		if !isConfigFile(fn) {
+			continue
+		}
+
+		// any errors will be reported when the file is parsed
+		f, diag := parseHCLFile(fsys, fn)
+		if diag.HasErrors() {
+			continue
+		}
+
+		blocks, _, _ := p.getBlocks(f)
+		for _, b := range blocks {
+			if b.Type != resources.TypeModule {
+				continue
+			}
+
+			attr := getAttribute(b.Body, "source")
+			if attr == nil {
+				continue
+			}
+
+			src, diag := attr.Expr.Value(nil)
+			if diag.HasErrors() || src.Type() != cty.String {
+				continue
+			}
+
+			dirs = append(dirs, joinPath(fsys, dirPath(fsys, fn), src.AsString()))
+		}
+	}
+
+	return dirs
+}
+
+// configFile contains the top level blocks of a config file
+type configFile struct {
+	name    string
+	blocks  []*hcl.Block
+	unknown hcl.Attributes
+}
+
+// parseFiles loads variables and resources from the given files, the variables
+// in all the files are parsed before the values from the variables files, the
+// environment and the variables map are applied once. Resources are parsed once
+// the values of all the variables are known.
+func (p *Parser) parseFiles(
+	goCtx context.Context,
+	fsys fs.FS,
 	ctx *hcl.EvalContext,
-	file string,
+	files []string,
 	c *Config,
 	variables map[string]string,
-	variablesFile []string) []error {
+	variablesFiles []string,
+	inputs map[string]cty.Value) []error {
+
+	errs := []error{}
+	configFiles := []configFile{}
 
 	// This must be done before any other process as the resources
 	// might reference the variables
-	err := p.parseVariablesInFile(ctx, file, c)
+	for _, file := range files {
+		if goCtx.Err() != nil {
+			return append(errs, fmt.Errorf("unable to parse file %s: %w", file, goCtx.Err()))
+		}
+
+		f, diag := parseHCLFile(fsys, file)
+		if !diag.HasErrors() {
+			cf := configFile{name: file}
+			cf.blocks, cf.unknown, diag = p.getBlocks(f)
+
+			if !diag.HasErrors() {
+				configFiles = append(configFiles, cf)
+				errs = append(errs, p.parseVariablesInFile(fsys, ctx, file, cf.blocks, c)...)
+			}
+		}
+
+		if diag.HasErrors() {
+			errs = append(errs, p.diagnosticErrors(file, diag)...)
+		}
+
+		if len(errs) > 0 && !p.options.ContinueOnError {
+			return errs
+		}
+	}
+
+	// override the default values for variables from files, the environment or
+	// the variables map in the configured order
+	errs = append(errs, p.applyVariables(fsys, ctx, c, variables, variablesFiles)...)
+	if len(errs) > 0 && !p.options.ContinueOnError {
+		return errs
+	}
+
+	// module inputs that are known when parsing take precedence, these are
+	// set again when the graph is walked
+	for k, v := range inputs {
+		setVariableValue(ctx, c, k, v, &resources.VariableSource{Type: resources.VariableSourceInput})
+	}
+
+	// the final values are converted to the type declared by the variable
+	if cerrs := convertVariables(ctx, c); cerrs != nil {
+		if !p.options.ContinueOnError {
+			return cerrs
+		}
+
+		errs = append(errs, cerrs...)
+	}
+
+	for _, cf := range configFiles {
+		if goCtx.Err() != nil {
+			return append(errs, fmt.Errorf("unable to parse file %s: %w", cf.name, goCtx.Err()))
+		}
+
+		errs = append(errs, p.parseResourcesInFile(goCtx, fsys, ctx, cf.name, cf.blocks, c, "", false, []string{})...)
+		if len(errs) > 0 && !p.options.ContinueOnError {
+			return errs
+		}
+
+		errs = append(errs, p.unknownPropertyErrors(cf.name, cf.unknown)...)
+		if len(errs) > 0 && !p.options.ContinueOnError {
+			return errs
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// unknownPropertyErrors returns the warnings for the properties in a file using
+// the HCL JSON syntax that are not top level blocks, these are reported in the
+// same way as unknown blocks in the native syntax
+func (p *Parser) unknownPropertyErrors(file string, unknown hcl.Attributes) []error {
+	attrs := []*hcl.Attribute{}
+	for _, a := range unknown {
+		attrs = append(attrs, a)
+	}
+
+	slices.SortFunc(attrs, func(a, b *hcl.Attribute) int {
+		return a.NameRange.Start.Byte - b.NameRange.Start.Byte
+	})
+
+	errs := []error{}
+	for _, a := range attrs {
+		errs = append(errs, p.unknownBlockError(file, a.Name, a.NameRange, a.NameRange))
+
+		if !p.options.ContinueOnError {
+			break
+		}
+	}
+
+	return errs
+}
+
+// diagnosticErrors converts the error diagnostics into ParserErrors, when
+// ContinueOnError is set all the diagnostics are returned otherwise only the first
+func (p *Parser) diagnosticErrors(file string, diag hcl.Diagnostics) []error {
+	errs := []error{}
+
+	for _, d := range diag {
+		if d.Severity != hcl.DiagError {
+			continue
+		}
+
+		de := &errors.ParserError{}
+
+		if d.Subject != nil {
+			de.Line = d.Subject.Start.Line
+			de.Column = d.Subject.Start.Column
+		}
+
+		de.Filename = file
+		de.Level = errors.ParserErrorLevelError
+		de.Message = fmt.Sprintf("unable to parse file: %s", d.Detail)
+
+		errs = append(errs, de)
+
+		if !p.options.ContinueOnError {
+			break
+		}
+	}
+
+	return errs
+}
+
+// applyVariables sets the values of variables from the sources in the order
+// defined by the VariablePrecedence option
+func (p *Parser) applyVariables(fsys fs.FS, ctx *hcl.EvalContext, c *Config, variables map[string]string, variablesFiles []string) []error {
+	errs := []error{}
+
+	for _, source := range variablePrecedence(p.options.VariablePrecedence) {
+		switch source {
+		case resources.VariableSourceFile:
+			for _, vf := range variablesFiles {
+				err := p.loadVariablesFromFile(fsys, ctx, c, vf)
+				if err != nil {
+					if !p.options.ContinueOnError {
+						return []error{err}
+					}
+
+					errs = append(errs, err)
+				}
+			}
+		case resources.VariableSourceEnv:
+			errs = append(errs, p.setVariablesFromEnv(ctx, c)...)
+		case resources.VariableSourceOption:
+			errs = append(errs, p.setVariables(ctx, c, variables)...)
+		}
+
+		if len(errs) > 0 && !p.options.ContinueOnError {
+			return errs
+		}
+	}
+
+	return errs
+}
+
+// loadVariablesFromFile loads variable values from a file, the file is decoded
+// using the decoder registered for its extension
+func (p *Parser) loadVariablesFromFile(fsys fs.FS, ctx *hcl.EvalContext, c *Config, path string) error {
+	src, err := fs.ReadFile(fsys, path)
 	if err != nil {
-		return []error{err}
+		de := &errors.ParserError{}
+		de.Filename = path
+		de.Level = errors.ParserErrorLevelError
+		de.Message = fmt.Sprintf("unable to read file: %s", err)
+
+		return de
+	}
+
+	values, err := p.variablesDecoder(path)(path, src, ctx)
+	if err != nil {
+		if de, ok := err.(*errors.ParserError); ok {
+			if de.Filename == "" {
+				de.Filename = path
+			}
+
+			return de
+		}
+
+		de := &errors.ParserError{}
+		de.Filename = path
+		de.Level = errors.ParserErrorLevelError
+		de.Message = fmt.Sprintf("unable to parse file: %s", err)
+
+		return de
+	}
+
+	for name, val := range values {
+		source := &resources.VariableSource{Type: resources.VariableSourceFile, Name: path}
+
+		if val.HasMark(markUntyped) {
+			v, _ := val.Unmark()
+
+			val, err = variableFromString(c, name, v.AsString(), source)
+			if err != nil {
+				return err
+			}
+		}
+
+		setVariableValue(ctx, c, name, val, source)
+	}
+
+	return nil
+}
+
+// setVariablesFromEnv sets variables from environment variables starting with
+// the VariableEnvPrefix
+func (p *Parser) setVariablesFromEnv(ctx *hcl.EvalContext, c *Config) []error {
+	errs := []error{}
+
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, p.options.VariableEnvPrefix) {
+			// values can contain = i.e. { env = "prod" }
+			name, value, _ := strings.Cut(e, "=")
+			key := strings.Replace(name, p.options.VariableEnvPrefix, "", -1)
+
+			source := &resources.VariableSource{Type: resources.VariableSourceEnv, Name: name}
+
+			val, err := variableFromString(c, key, value, source)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			setVariableValue(ctx, c, key, val, source)
+		}
+	}
+
+	return errs
+}
+
+// setVariables allow variables to be set from a collection
+func (p *Parser) setVariables(ctx *hcl.EvalContext, c *Config, vars map[string]string) []error {
+	errs := []error{}
+
+	for k, v := range vars {
+		source := &resources.VariableSource{Type: resources.VariableSourceOption}
+
+		val, err := variableFromString(c, k, v, source)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		setVariableValue(ctx, c, k, val, source)
+	}
+
+	return errs
+}
+
+// parseVariablesInFile parses the variable blocks in a config file
+func (p *Parser) parseVariablesInFile(fsys fs.FS, ctx *hcl.EvalContext, file string, blocks []*hcl.Block, c *Config) []error {
+	errs := []error{}
+
+	for _, b := range blocks {
+		switch b.Type {
+		case resources.TypeVariable:
+			// variables with no name are reported when the resources are parsed
+			if len(b.Labels) == 0 {
+				continue
+			}
+
+			r, _ := p.registeredTypes.CreateResource(resources.TypeVariable, b.Labels[0])
+			v := r.(*resources.Variable)
+
+			r.Metadata().File = file
+			r.Metadata().Line = b.TypeRange.Start.Line
+			r.Metadata().Column = b.TypeRange.Start.Column
+
+			// add the checksum for the resource
+			br := blockRange(b)
+			cs, err := readFileLocation(fsys, br.Filename, br.Start.Line, br.Start.Column, br.End.Line, br.End.Column)
+			if err != nil {
+				panic(err)
+			}
+
+			r.Metadata().Checksum.Parsed = HashString(cs)
+
+			err = decodeBody(ctx, c, file, b, v, false)
+			if err != nil {
+				if !p.options.ContinueOnError {
+					return []error{err}
+				}
+
+				errs = append(errs, err)
+				continue
+			}
+
+			// add the variable to the context
+			c.AppendResource(v)
+
+			// variables without a default are required and must be set by the
+			// caller, this is checked once all the values have been applied
+			if attr, ok := v.Default.(*hcl.Attribute); ok {
+				val, _ := attr.Expr.Value(ctx)
+				if setContextVariableIfMissing(ctx, v.Meta.Name, val) {
+					v.Source = &resources.VariableSource{Type: resources.VariableSourceDefault}
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// parseResourcesInFile parses the blocks in a hcl file and adds any found resources to the config
+func (p *Parser) parseResourcesInFile(goCtx context.Context, fsys fs.FS, ctx *hcl.EvalContext, file string, blocks []*hcl.Block, c *Config, moduleName string, disabled bool, dependsOn []string) []error {
+	errs := []error{}
+
+	for _, b := range blocks {
+		var blockErrs []error
+
+		// check the resource has a name, moved blocks are the only blocks
+		// without a name
+		if len(b.Labels) == 0 && b.Type != typeMoved {
+			de := &errors.ParserError{}
+			de.Line = b.TypeRange.Start.Line
+			de.Column = b.TypeRange.Start.Column
+			de.Filename = file
+			de.Message = fmt.Sprintf("resource '%s' has no name, please specify resources using the syntax 'resource_type \"name\" {}'", b.Type)
+
+			blockErrs = []error{de}
+		} else {
+			blockErrs = p.parseBlock(goCtx, fsys, ctx, file, b, c, moduleName, disabled, dependsOn)
+		}
+
+		if blockErrs != nil {
+			if !p.options.ContinueOnError {
+				return blockErrs
+			}
+
+			errs = append(errs, blockErrs...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
 	}
 
-	// override any variables from files
-	for _, vf := range variablesFile {
-		err := p.loadVariablesFromFile(ctx, vf)
+	return nil
+}
+
+// parseBlock parses a single top level block and adds any found resources to the config
+func (p *Parser) parseBlock(goCtx context.Context, fsys fs.FS, ctx *hcl.EvalContext, file string, b *hcl.Block, c *Config, moduleName string, disabled bool, dependsOn []string) []error {
+	// create the registered type if not a variable or output
+	// variables and outputs are processed in a separate run
+	switch b.Type {
+	case resources.TypeVariable:
+		return nil
+	case resources.TypeModule:
+		instances, err := p.expandBlock(ctx, c, file, b, moduleName)
 		if err != nil {
 			return []error{err}
 		}
+
+		errs := []error{}
+		for _, i := range instances {
+			err := p.parseModule(goCtx, fsys, i.ctx, c, file, i.block, moduleName, dependsOn, i.index, i.key)
+			if err != nil {
+				if !p.options.ContinueOnError {
+					return err
+				}
+
+				errs = append(errs, err...)
+			}
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+	case types.TypeResource, types.TypeData:
+		instances, err := p.expandBlock(ctx, c, file, b, moduleName)
+		if err != nil {
+			return []error{err}
+		}
+
+		errs := []error{}
+		for _, i := range instances {
+			err := p.parseResource(i.ctx, c, file, i.block, moduleName, dependsOn, disabled, i.index, i.key)
+			if err != nil {
+				if !p.options.ContinueOnError {
+					return []error{err}
+				}
+
+				errs = append(errs, err)
+			}
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+	case resources.TypeOutput, resources.TypeLocal, resources.TypeCheck:
+		err := p.parseResource(ctx, c, file, b, moduleName, dependsOn, disabled, nil, nil)
+		if err != nil {
+			return []error{err}
+		}
+	case typeMoved:
+		if err := parseMoved(c, b); err != nil {
+			return []error{err}
+		}
+	default:
+		// blocks registered with RegisterBlockType
+		if _, ok := p.customBlockType(b.Type); ok {
+			err := p.parseResource(ctx, c, file, b, moduleName, dependsOn, disabled, nil, nil)
+			if err != nil {
+				return []error{err}
+			}
+
+			return nil
+		}
+
+		return []error{p.unknownBlockError(file, b.Type, b.TypeRange, b.DefRange)}
+	}
+
+	return nil
+}
+
+// unknownBlockError returns the warning for a top level block that is not
+// supported by the parser
+func (p *Parser) unknownBlockError(file, typ string, typeRange, defRange hcl.Range) *errors.ParserError {
+	keywords := []string{}
+	for _, k := range p.blockKeywords() {
+		keywords = append(keywords, fmt.Sprintf("'%s'", k))
+	}
+
+	de := &errors.ParserError{}
+	de.Line = typeRange.Start.Line
+	de.Column = typeRange.Start.Column
+	de.Filename = file
+	de.Level = errors.ParserErrorLevelWarning
+	de.Message = fmt.Sprintf("unable to process stanza '%s' in file %s at %d,%d , only %s, and %s are valid stanza blocks", typ, file, defRange.Start.Line, defRange.Start.Column, strings.Join(keywords[:len(keywords)-1], ", "), keywords[len(keywords)-1])
+
+	return de
+}
+
+// metaArgumentsSchema defines the attributes that control how a block is
+// expanded, these are not part of the resource and are hidden from the body
+var metaArgumentsSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "count"},
+		{Name: "for_each"},
+	},
+}
+
+// blockInstance is a single instance of a block that has been expanded using
+// the count or for_each meta-arguments
+type blockInstance struct {
+	ctx   *hcl.EvalContext
+	block *hcl.Block
+	index *int
+	key   *string
+}
+
+// expandBlock returns the instances defined by a resource or module block, blocks
+// that do not set count or for_each return a single instance. The meta-arguments
+// are evaluated when the configuration is parsed so they can only reference
+// variables and literals. Every instance has its own child context containing
+// count.index or each.key and each.value.
+func (p *Parser) expandBlock(ctx *hcl.EvalContext, c *Config, file string, b *hcl.Block, moduleName string) ([]blockInstance, error) {
+	fqrn := resources.FQRN{Module: moduleName, Type: resources.TypeModule}
+	switch {
+	case (b.Type == types.TypeResource || b.Type == types.TypeData) && len(b.Labels) == 2:
+		fqrn.Type = b.Labels[0]
+		fqrn.Resource = b.Labels[1]
+		fqrn.Data = b.Type == types.TypeData
+	case b.Type == resources.TypeModule && len(b.Labels) == 1:
+		fqrn.Resource = b.Labels[0]
+	default:
+		// invalid labels are reported when the block is parsed
+		return []blockInstance{{ctx: ctx, block: b}}, nil
+	}
+
+	countAttr := getAttribute(b.Body, "count")
+	forEachAttr := getAttribute(b.Body, "for_each")
+
+	if countAttr == nil && forEachAttr == nil {
+		return []blockInstance{{ctx: ctx, block: b}}, nil
+	}
+
+	if countAttr != nil && forEachAttr != nil {
+		return nil, metaArgumentError(file, forEachAttr, "count and for_each can not be set on the same block")
+	}
+
+	attr := countAttr
+	if attr == nil {
+		attr = forEachAttr
+	}
+
+	refs, err := exprReferences(c.blockTypes, attr.Expr)
+	if err != nil || len(refs) > 0 {
+		return nil, metaArgumentError(file, attr, fmt.Sprintf("%s can only reference variables and literals as it is evaluated when the configuration is parsed", attr.Name))
+	}
+
+	var instances []blockInstance
+	if countAttr != nil {
+		instances, err = countInstances(ctx, file, countAttr)
+	} else {
+		instances, err = forEachInstances(ctx, file, forEachAttr)
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
-	// override default values for variables from environment or variables map
-	p.setVariables(ctx, variables)
+	// hide the meta-arguments so that they are not decoded into the resource
+	_, body, _ := b.Body.PartialContent(metaArgumentsSchema)
+	block := *b
+	block.Body = body
 
-	errs := p.parseResourcesInFile(ctx, file, c, "", false, []string{})
-	if errs != nil {
-		return errs
+	for i := range instances {
+		instances[i].block = &block
 	}
 
-	return nil
+	if len(instances) == 0 {
+		c.addEmpty(fqrn)
+	}
+
+	return instances, nil
 }
 
-// loadVariablesFromFile loads variable values from a file
-func (p *Parser) loadVariablesFromFile(ctx *hcl.EvalContext, path string) error {
-	parser := hclparse.NewParser()
+// countInstances returns an instance with count.index set for every element of count
+func countInstances(ctx *hcl.EvalContext, file string, attr *hcl.Attribute) ([]blockInstance, error) {
+	var count int
+	diags := gohcl.DecodeExpression(&unmarkExpr{Expression: attr.Expr}, ctx, &count)
+	if diags.HasErrors() {
+		return nil, metaArgumentError(file, attr, fmt.Sprintf("unable to evaluate count: %s", diags.Error()))
+	}
 
-	f, diag := parser.ParseHCLFile(path)
-	if diag.HasErrors() {
-		de := &errors.ParserError{}
-		de.Line = diag[0].Subject.Start.Line
-		de.Column = diag[0].Subject.Start.Line
-		de.Filename = path
-		de.Level = errors.ParserErrorLevelError
-		de.Message = fmt.Sprintf("unable to parse file: %s", diag[0].Detail)
+	if count < 0 {
+		return nil, metaArgumentError(file, attr, fmt.Sprintf("count must be greater than or equal to 0, got %d", count))
 	}
 
-	attrs, _ := f.Body.JustAttributes()
-	for name, attr := range attrs {
-		val, _ := attr.Expr.Value(ctx)
+	instances := []blockInstance{}
+	for i := range count {
+		child := ctx.NewChild()
+		child.Variables = map[string]cty.Value{
+			"count": cty.ObjectVal(map[string]cty.Value{
+				"index": cty.NumberIntVal(int64(i)),
+			}),
+		}
 
-		setContextVariable(ctx, name, val)
+		index := i
+		instances = append(instances, blockInstance{ctx: child, index: &index})
 	}
 
-	return nil
+	return instances, nil
 }
 
-// setVariables allow variables to be set from a collection or environment variables
-// Precedence should be file, env, vars
-func (p *Parser) setVariables(ctx *hcl.EvalContext, vars map[string]string) {
-	// first any vars defined as environment variables
-	for _, e := range os.Environ() {
-		if strings.HasPrefix(e, p.options.VariableEnvPrefix) {
-			parts := strings.Split(e, "=")
-
-			if len(parts) == 2 {
-				key := strings.Replace(parts[0], p.options.VariableEnvPrefix, "", -1)
-				setContextVariable(ctx, key, valueFromString(parts[1]))
-			}
-		}
+// forEachInstances returns an instance with each.key and each.value set for every
+// element of a map or object, for a list or set of strings the key and the value
+// are the element. Instances are returned in the order of their keys.
+func forEachInstances(ctx *hcl.EvalContext, file string, attr *hcl.Attribute) ([]blockInstance, error) {
+	val, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, metaArgumentError(file, attr, fmt.Sprintf("unable to evaluate for_each: %s", diags.Error()))
 	}
 
-	// then set vars
-	for k, v := range vars {
-		setContextVariable(ctx, k, valueFromString(v))
+	if val.IsNull() || !val.IsWhollyKnown() {
+		return nil, metaArgumentError(file, attr, "for_each must be set to a known value")
 	}
-}
 
-func valueFromString(v string) cty.Value {
-	// attempt to parse the string value into a known type
-	if val, err := strconv.ParseInt(v, 10, 0); err == nil {
-		return cty.NumberIntVal(val)
+	// the keys are part of the address of the instances and can not be sensitive
+	if val.ContainsMarked() {
+		return nil, metaArgumentError(file, attr, "for_each can not contain sensitive values as the keys are used in the address of the instances")
 	}
 
-	if val, err := strconv.ParseBool(v); err == nil {
-		return cty.BoolVal(val)
+	elements := map[string]cty.Value{}
+	switch {
+	case val.Type().IsObjectType() || val.Type().IsMapType():
+		for k, v := range val.AsValueMap() {
+			elements[k] = v
+		}
+	case val.Type().IsTupleType() || val.Type().IsListType() || val.Type().IsSetType():
+		for _, v := range val.AsValueSlice() {
+			if v.Type() != cty.String {
+				return nil, metaArgumentError(file, attr, "for_each must be a map or a list of strings")
+			}
+
+			elements[v.AsString()] = v
+		}
+	default:
+		return nil, metaArgumentError(file, attr, "for_each must be a map or a list of strings")
 	}
 
-	// otherwise return a string
-	return cty.StringVal(v)
-}
+	keys := []string{}
+	for k := range elements {
+		if err := validateInstanceKey(k); err != nil {
+			return nil, metaArgumentError(file, attr, err.Error())
+		}
 
-// ParseVariableFile parses a config file for variables
-func (p *Parser) parseVariablesInFile(ctx *hcl.EvalContext, file string, c *Config) error {
-	parser := hclparse.NewParser()
+		keys = append(keys, k)
+	}
 
-	f, diag := parser.ParseHCLFile(file)
-	if diag.HasErrors() {
-		de := &errors.ParserError{}
+	sort.Strings(keys)
 
-		if diag[0].Subject != nil {
-			de.Line = diag[0].Subject.Start.Line
-			de.Column = diag[0].Subject.Start.Line
+	instances := []blockInstance{}
+	for _, k := range keys {
+		child := ctx.NewChild()
+		child.Variables = map[string]cty.Value{
+			"each": cty.ObjectVal(map[string]cty.Value{
+				"key":   cty.StringVal(k),
+				"value": elements[k],
+			}),
 		}
 
-		de.Level = errors.ParserErrorLevelError
-		de.Filename = file
-		de.Message = fmt.Sprintf("unable to parse file: %s", diag[0].Detail)
-
-		return de
-	}
-
-	body, ok := f.Body.(*hclsyntax.Body)
-	if !ok {
-		panic("Error getting body")
+		key := k
+		instances = append(instances, blockInstance{ctx: child, key: &key})
 	}
 
-	for _, b := range body.Blocks {
-		switch b.Type {
-		case resources.TypeVariable:
-			r, _ := p.registeredTypes.CreateResource(resources.TypeVariable, b.Labels[0])
-			v := r.(*resources.Variable)
-
-			// add the checksum for the resource
-			cs, err := ReadFileLocation(b.Range().Filename, b.Range().Start.Line, b.TypeRange.Start.Column, b.Range().End.Line, b.Range().End.Column)
-			if err != nil {
-				panic(err)
-			}
+	return instances, nil
+}
 
-			r.Metadata().Checksum.Parsed = HashString(cs)
+func metaArgumentError(file string, attr *hcl.Attribute, msg string) *errors.ParserError {
+	de := &errors.ParserError{}
+	de.Line = attr.Range.Start.Line
+	de.Column = attr.Range.Start.Column
+	de.Filename = file
+	de.Level = errors.ParserErrorLevelError
+	de.Message = msg
 
-			err = decodeBody(ctx, c, file, b, v, false)
-			if err != nil {
-				return err
-			}
+	return de
+}
 
-			// add the variable to the context
-			c.AppendResource(v)
+// jsonFileSchema returns the top level blocks in a file using the HCL JSON
+// syntax, JSON has no native notion of labels so the labels for each block
+// type must be known to decode the file
+func (p *Parser) jsonFileSchema() *hcl.BodySchema {
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: typeMoved},
+		},
+	}
 
-			val, _ := v.Default.(*hcl.Attribute).Expr.Value(ctx)
-			setContextVariableIfMissing(ctx, v.Meta.Name, val)
-		}
+	for _, bt := range p.blockTypes.Sorted() {
+		schema.Blocks = append(schema.Blocks, hcl.BlockHeaderSchema{Type: bt.Name, LabelNames: bt.Labels})
 	}
 
-	return nil
+	return schema
 }
 
-// parseResourcesInFile parses a hcl file and adds any found resources to the config
-func (p *Parser) parseResourcesInFile(ctx *hcl.EvalContext, file string, c *Config, moduleName string, disabled bool, dependsOn []string) []error {
-	parser := hclparse.NewParser()
+// getBlocks returns the top level blocks defined in the file, for files using
+// the HCL JSON syntax the properties that are not top level blocks are also
+// returned
+func (p *Parser) getBlocks(f *hcl.File) ([]*hcl.Block, hcl.Attributes, hcl.Diagnostics) {
+	// blocks in the native syntax are returned as they are defined, this allows
+	// the parser to validate the labels and report unknown blocks
+	if body, ok := f.Body.(*hclsyntax.Body); ok {
+		blocks := []*hcl.Block{}
+		for _, b := range body.Blocks {
+			blocks = append(blocks, b.AsHCLBlock())
+		}
 
-	f, diag := parser.ParseHCLFile(file)
-	if diag.HasErrors() {
-		de := &errors.ParserError{}
-		de.Line = diag[0].Subject.Start.Line
-		de.Column = diag[0].Subject.Start.Line
-		de.Filename = file
-		de.Message = fmt.Sprintf("unable to parse file: %s", diag[0].Detail)
+		return blocks, nil, nil
+	}
 
-		// check the error types and determine if we should set a warning or error
-		level := errors.ParserErrorLevelWarning
+	content, remain, diags := f.Body.PartialContent(p.jsonFileSchema())
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
 
-		for _, e := range diag.Errs() {
-			err, ok := e.(*hcl.Diagnostic)
-			if !ok {
-				continue
-			}
+	// any remaining properties are blocks that the parser does not support
+	unknown, _ := remain.JustAttributes()
 
-			if err.Summary == "Error in function call" {
-				level = errors.ParserErrorLevelError
-				break
-			}
-		}
+	return content.Blocks, unknown, nil
+}
 
-		de.Level = level
-		return []error{de}
+// getAttribute returns the attribute with the given name from the body,
+// if the attribute does not exist nil is returned
+func getAttribute(b hcl.Body, name string) *hcl.Attribute {
+	if body, ok := b.(*hclsyntax.Body); ok {
+		return body.Attributes[name].AsHCLAttribute()
 	}
 
-	body, ok := f.Body.(*hclsyntax.Body)
-	if !ok {
-		// this should never happen, body should always be a hclsyntax.Body
-		panic("Error getting body")
+	content, _, _ := b.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: name}},
+	})
+
+	if content == nil {
+		return nil
 	}
 
-	for _, b := range body.Blocks {
-		// check the resource has a name
-		if len(b.Labels) == 0 {
-			de := &errors.ParserError{}
-			de.Line = b.TypeRange.Start.Line
-			de.Column = b.TypeRange.Start.Column
-			de.Filename = file
-			de.Message = fmt.Sprintf("resource '%s' has no name, please specify resources using the syntax 'resource_type \"name\" {}'", b.Type)
+	return content.Attributes[name]
+}
 
-			return []error{de}
-		}
+// blockRange returns the range of the block including the body
+func blockRange(b *hcl.Block) hcl.Range {
+	if body, ok := b.Body.(*hclsyntax.Body); ok {
+		return hcl.RangeBetween(b.TypeRange, body.SrcRange)
+	}
 
-		// create the registered type if not a variable or output
-		// variables and outputs are processed in a separate run
-		switch b.Type {
-		case resources.TypeVariable:
-			continue
-		case resources.TypeModule:
-			err := p.parseModule(ctx, c, file, b, moduleName, dependsOn)
-			if err != nil {
-				return err
-			}
-		case resources.TypeOutput:
-			fallthrough
-		case resources.TypeLocal:
-			fallthrough
-		case types.TypeResource:
-			err := p.parseResource(ctx, c, file, b, moduleName, dependsOn, disabled)
-			if err != nil {
-				return []error{err}
-			}
-		default:
-			de := &errors.ParserError{}
-			de.Line = b.TypeRange.Start.Line
-			de.Column = b.TypeRange.Start.Column
-			de.Filename = file
-			de.Level = errors.ParserErrorLevelWarning
-			de.Message = fmt.Sprintf("unable to process stanza '%s' in file %s at %d,%d , only 'variable', 'resource', 'module', and 'output' are valid stanza blocks", b.Type, file, b.Range().Start.Line, b.Range().Start.Column)
+	// in the JSON syntax the type range is shared by all blocks of the same
+	// type, the def range is the start of the block's object
+	return hcl.RangeBetween(b.DefRange, b.Body.MissingItemRange())
+}
 
-			return []error{de}
-		}
+// bodyRange returns the range of the body
+func bodyRange(b hcl.Body) hcl.Range {
+	if body, ok := b.(*hclsyntax.Body); ok {
+		return body.SrcRange
 	}
 
-	return nil
+	return b.MissingItemRange()
 }
 
-func setDependsOn(ctx *hcl.EvalContext, r types.Resource, b *hclsyntax.Body, dependsOn []string) error {
+func setDependsOn(ctx *hcl.EvalContext, c *Config, r types.Resource, b hcl.Body, dependsOn []string) error {
 	for _, d := range dependsOn {
 		r.AddDependency(d)
 	}
 
-	if attr, ok := b.Attributes["depends_on"]; ok {
+	if attr := getAttribute(b, "depends_on"); attr != nil {
 		dependsOnVal, diags := attr.Expr.Value(ctx)
 		if diags.HasErrors() {
 			return fmt.Errorf("unable to read depends_on attribute: %s", diags.Error())
@@ -557,7 +1366,7 @@ func setDependsOn(ctx *hcl.EvalContext, r types.Resource, b *hclsyntax.Body, dep
 		// depends on is a slice of string
 		dependsOnSlice := dependsOnVal.AsValueSlice()
 		for _, d := range dependsOnSlice {
-			_, err := resources.ParseFQRN(d.AsString())
+			_, err := c.blockTypes.ParseFQRN(d.AsString())
 			if err != nil {
 				return fmt.Errorf("invalid dependency %s, %s", d.AsString(), err)
 			}
@@ -569,7 +1378,7 @@ func setDependsOn(ctx *hcl.EvalContext, r types.Resource, b *hclsyntax.Body, dep
 	return nil
 }
 
-func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hclsyntax.Block, moduleName string, dependsOn []string) []error {
+func (p *Parser) parseModule(goCtx context.Context, fsys fs.FS, ctx *hcl.EvalContext, c *Config, file string, b *hcl.Block, moduleName string, dependsOn []string, index *int, key *string) []error {
 	// check the module has a name
 	if len(b.Labels) != 1 {
 		de := &errors.ParserError{}
@@ -597,6 +1406,8 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 	rt, _ := resources.DefaultResources().CreateResource(string(resources.TypeModule), b.Labels[0])
 
 	rt.Metadata().Module = moduleName
+	rt.Metadata().Index = index
+	rt.Metadata().Key = key
 	rt.Metadata().File = file
 	rt.Metadata().Line = b.TypeRange.Start.Line
 	rt.Metadata().Column = b.TypeRange.Start.Column
@@ -613,7 +1424,7 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 		return []error{de}
 	}
 
-	derr := setDependsOn(ctx, rt, b.Body, dependsOn)
+	derr := setDependsOn(ctx, c, rt, b.Body, dependsOn)
 	if derr != nil {
 		de := &errors.ParserError{}
 		de.Line = b.TypeRange.Start.Line
@@ -627,7 +1438,8 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 
 	// we need to fetch the source so that we can process the child resources
 	// "source" is the attribute but we need to read this manually
-	if _, ok := b.Body.Attributes["source"]; !ok {
+	srcAttr := getAttribute(b.Body, "source")
+	if srcAttr == nil {
 		de := &errors.ParserError{}
 		de.Line = b.TypeRange.Start.Line
 		de.Column = b.TypeRange.Start.Column
@@ -640,7 +1452,7 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 
 	// we need to fetch the source so that we can process the child resources
 	// "source" is the attribute but we need to read this manually
-	src, diags := b.Body.Attributes["source"].Expr.Value(ctx)
+	src, diags := srcAttr.Expr.Value(ctx)
 	if diags.HasErrors() {
 		de := &errors.ParserError{}
 		de.Line = b.TypeRange.Start.Line
@@ -653,8 +1465,8 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 	}
 
 	version := "latest"
-	if b.Body.Attributes["version"] != nil {
-		v, diags := b.Body.Attributes["version"].Expr.Value(ctx)
+	if versionAttr := getAttribute(b.Body, "version"); versionAttr != nil {
+		v, diags := versionAttr.Expr.Value(ctx)
 		if diags.HasErrors() {
 			de := &errors.ParserError{}
 			de.Line = b.TypeRange.Start.Line
@@ -673,10 +1485,11 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 
 	// src could be a registry url, github repository or a relative folder
 	// first check if it is a folder, we need to make it absolute relative to the current file
-	dir := path.Dir(file)
-	moduleSrc := path.Join(dir, src.AsString())
+	dir := dirPath(fsys, file)
+	moduleSrc := joinPath(fsys, dir, src.AsString())
+	moduleFS := fsys
 
-	fi, serr := os.Stat(moduleSrc)
+	fi, serr := fs.Stat(fsys, moduleSrc)
 	if serr != nil || !fi.IsDir() {
 		moduleURL := src.AsString()
 
@@ -700,11 +1513,11 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 			}
 
 			// if we can't create a registry, it is not a module registry so we can ignore the error
-			r, err := registry.New(host, token)
+			r, err := registry.NewWithContext(goCtx, host, token)
 			if err == nil {
 				// get all available versions of the module from the registry
 				// check if the requested version exists
-				versions, err := r.GetModuleVersions(namespace, name)
+				versions, err := registry.GetModuleVersionsWithContext(goCtx, r, namespace, name)
 				if err != nil {
 					de := &errors.ParserError{}
 					de.Line = b.TypeRange.Start.Line
@@ -741,7 +1554,7 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 					}
 				}
 
-				module, err := r.GetModule(namespace, name, version)
+				module, err := registry.GetModuleWithContext(goCtx, r, namespace, name, version)
 				if err == nil {
 					// if we get back a module url from the registry,
 					// set the source to the returned url
@@ -762,7 +1575,7 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 		// is not a directory fetch from source using go getter
 		gg := NewGoGetter()
 
-		mp, err := gg.Get(moduleURL, p.options.ModuleCache, false)
+		mp, err := getWithContext(goCtx, gg, moduleURL, p.options.ModuleCache, false)
 		if err != nil {
 			de := &errors.ParserError{}
 			de.Line = b.TypeRange.Start.Line
@@ -774,17 +1587,35 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 			return []error{de}
 		}
 
+		// remote modules are always downloaded to the module cache on disk
 		moduleSrc = mp
+		moduleFS = osFS{}
 	}
 
 	// create a new config and add the resources later
-	moduleConfig := NewConfig()
+	moduleConfig := p.newConfig()
 
 	// modules should have their own context so that variables are not globally scoped
-	subContext := buildContext(moduleSrc, p.registeredFunctions)
+	subContext := buildContext(moduleFS, moduleSrc, p.registeredFunctions)
+
+	// module variables are set when the graph is walked, variables that do not
+	// reference other resources are also set now so that they can be used by count
+	var inputs map[string]cty.Value
+	if attr := getAttribute(b.Body, "variables"); attr != nil {
+		if refs, err := exprReferences(c.blockTypes, attr.Expr); err == nil && len(refs) == 0 {
+			val, diags := attr.Expr.Value(ctx)
+			val, marks := val.Unmark()
+			if !diags.HasErrors() && val.Type().IsObjectType() && val.IsWhollyKnown() && !val.IsNull() {
+				inputs = map[string]cty.Value{}
+				for k, v := range val.AsValueMap() {
+					inputs[k] = v.WithMarks(marks)
+				}
+			}
+		}
+	}
 
-	errs := p.parseDirectory(subContext, moduleSrc, moduleConfig)
-	if errs != nil {
+	errs := p.parseDirectory(goCtx, moduleFS, subContext, moduleSrc, moduleConfig, false, inputs)
+	if errs != nil && !p.options.ContinueOnError {
 		return errs
 	}
 
@@ -793,6 +1624,10 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 	// add the module
 	c.addResource(rt, ctx, b.Body)
 
+	// instances of a module created with count or for_each include the selector
+	// in the name of the module i.e. module["api"]
+	name = name + resources.InstanceSelector(index, key)
+
 	// we need to add the module name to all the returned resources
 	for _, r := range moduleConfig.Resources {
 		// ensure the module name has the parent appended to it
@@ -810,35 +1645,50 @@ func (p *Parser) parseModule(ctx *hcl.EvalContext, c *Config, file string, b *hc
 		}
 
 		// depends on is a property of the embedded type we need to set this manually
-		err = setDependsOn(ctx, rt, b.Body, dependsOn)
+		err = setDependsOn(ctx, c, rt, b.Body, dependsOn)
 		if err != nil {
 			return []error{err}
 		}
 
 		err = c.addResource(r, ctx, bdy)
 		if err != nil {
-			return []error{err}
+			return append(errs, err)
 		}
 	}
 
-	return nil
+	for s := range moduleConfig.sensitive {
+		c.sensitive[s] = true
+	}
+
+	// resources expanded into no instances are relative to the module
+	for e := range moduleConfig.empty {
+		fqrn, _ := c.blockTypes.ParseFQRN(e)
+		c.addEmpty(fqrn.AppendParentModule(name))
+	}
+
+	// moved blocks are relative to the module
+	for _, m := range moduleConfig.moved {
+		c.moved = append(c.moved, moved{From: m.From.AppendParentModule(name), To: m.To.AppendParentModule(name)})
+	}
+
+	return errs
 }
 
-func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *hclsyntax.Block, moduleName string, dependsOn []string, disabled bool) error {
+func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *hcl.Block, moduleName string, dependsOn []string, disabled bool, index *int, key *string) error {
 	var rt types.Resource
 	var err error
 
 	ignoreErrors := false
 
 	switch b.Type {
-	case types.TypeResource:
-		// if the type is resource there should be two labels, one for the type and one for the name
+	case types.TypeResource, types.TypeData:
+		// if the type is resource or data there should be two labels, one for the type and one for the name
 		if len(b.Labels) != 2 {
 			de := &errors.ParserError{}
 			de.Line = b.TypeRange.Start.Line
 			de.Column = b.TypeRange.Start.Column
 			de.Filename = file
-			de.Message = `"invalid formatting for 'resource' stanza, resources should have a name and a type, i.e. 'resource "type" "name" {}'`
+			de.Message = fmt.Sprintf(`"invalid formatting for '%s' stanza, resources should have a name and a type, i.e. '%s "type" "name" {}'`, b.Type, b.Type)
 			de.Level = errors.ParserErrorLevelError
 
 			return de
@@ -856,6 +1706,12 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 			return de
 		}
 
+		// data sources are created from their own registry
+		registeredTypes := p.registeredTypes
+		if b.Type == types.TypeData {
+			registeredTypes = p.registeredDataTypes
+		}
+
 		// PrimativesOnly parse to ResourceBase
 		if p.options.PrimativesOnly {
 			rt = &types.ResourceBase{
@@ -869,7 +1725,7 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 			// ignore any errors when parsing
 			ignoreErrors = true
 		} else {
-			rt, err = p.registeredTypes.CreateResource(b.Labels[0], name)
+			rt, err = registeredTypes.CreateResource(b.Labels[0], name)
 			if err != nil {
 				de := &errors.ParserError{}
 				de.Line = b.TypeRange.Start.Line
@@ -882,6 +1738,9 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 			}
 		}
 
+		rt.Metadata().Data = b.Type == types.TypeData
+		rt.Metadata().SchemaVersion = p.schemaVersion(b.Labels[0], rt.Metadata().Data)
+
 	case resources.TypeLocal:
 		// if the type is local check there is one label
 		if len(b.Labels) != 1 {
@@ -919,6 +1778,43 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 			return de
 		}
 
+	case resources.TypeCheck:
+		// if the type is check there is one label
+		if len(b.Labels) != 1 {
+			de := &errors.ParserError{}
+			de.Line = b.TypeRange.Start.Line
+			de.Column = b.TypeRange.Start.Column
+			de.Filename = file
+			de.Level = errors.ParserErrorLevelError
+			de.Message = `invalid formatting for 'check' stanza, checks should have a name, i.e. 'check "name" {}'`
+
+			return de
+		}
+
+		name := b.Labels[0]
+		if err := validateResourceName(name); err != nil {
+			de := &errors.ParserError{}
+			de.Line = b.TypeRange.Start.Line
+			de.Column = b.TypeRange.Start.Column
+			de.Filename = file
+			de.Level = errors.ParserErrorLevelError
+			de.Message = err.Error()
+
+			return de
+		}
+
+		rt, err = p.registeredTypes.CreateResource(resources.TypeCheck, name)
+		if err != nil {
+			de := &errors.ParserError{}
+			de.Line = b.TypeRange.Start.Line
+			de.Column = b.TypeRange.Start.Column
+			de.Filename = file
+			de.Level = errors.ParserErrorLevelError
+			de.Message = fmt.Sprintf(`unable to create check, this error should never happen %s`, err)
+
+			return de
+		}
+
 	case resources.TypeOutput:
 		// if the type is output check there is one label
 		if len(b.Labels) != 1 {
@@ -955,9 +1851,23 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 
 			return de
 		}
+
+	default:
+		// blocks registered with RegisterBlockType
+		bt, ok := p.customBlockType(b.Type)
+		if !ok {
+			return fmt.Errorf("unable to create resource, block type '%s' has not been registered", b.Type)
+		}
+
+		rt, err = p.createBlockResource(file, b, bt)
+		if err != nil {
+			return err
+		}
 	}
 
 	rt.Metadata().Module = moduleName
+	rt.Metadata().Index = index
+	rt.Metadata().Key = key
 	rt.Metadata().File = file
 	rt.Metadata().Line = b.TypeRange.Start.Line
 	rt.Metadata().Column = b.TypeRange.Start.Column
@@ -973,18 +1883,25 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 		return de
 	}
 
+	// blocks that are not processed in the graph are decoded now
+	if bt, ok := p.customBlockType(b.Type); ok && !bt.DAG && !ignoreErrors {
+		if err := decodeStaticResource(ctx, c, file, b, rt); err != nil {
+			return err
+		}
+	}
+
 	// if we have an output, get the description
 	// this is only needed when parsing primatives as
 	// this value is normally set during walk
-	if rt.Metadata().Type == resources.TypeOutput && b.Body.Attributes["description"] != nil {
-		desc, diags := b.Body.Attributes["description"].Expr.Value(ctx)
+	if attr := getAttribute(b.Body, "description"); rt.Metadata().Type == resources.TypeOutput && attr != nil {
+		desc, diags := attr.Expr.Value(ctx)
 		if !diags.HasErrors() {
 			rt.(*resources.Output).Description = desc.AsString()
 		}
 	}
 
 	// depends on is a property of the embedded type we need to set this manually
-	err = setDependsOn(ctx, rt, b.Body, dependsOn)
+	err = setDependsOn(ctx, c, rt, b.Body, dependsOn)
 	if err != nil {
 		de := &errors.ParserError{}
 		de.Line = b.TypeRange.Start.Line
@@ -1003,7 +1920,7 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 		de.Column = b.TypeRange.Start.Column
 		de.Filename = file
 		de.Level = errors.ParserErrorLevelError
-		de.Message = fmt.Sprintf(`unable to add resource "%s" to config %s`, resources.FQRNFromResource(rt).String(), err)
+		de.Message = fmt.Sprintf(`unable to add resource "%s" to config %s`, p.blockTypes.FQRNFromResource(rt).String(), err)
 
 		return de
 	}
@@ -1011,14 +1928,18 @@ func (p *Parser) parseResource(ctx *hcl.EvalContext, c *Config, file string, b *
 	return nil
 }
 
-func setContextVariableIfMissing(ctx *hcl.EvalContext, key string, value cty.Value) {
+// setContextVariableIfMissing sets the variable when it has not already been
+// set, returns true when the variable is set
+func setContextVariableIfMissing(ctx *hcl.EvalContext, key string, value cty.Value) bool {
 	if m, ok := ctx.Variables["variable"]; ok {
 		if _, ok := m.AsValueMap()[key]; ok {
-			return
+			return false
 		}
 	}
 
 	setContextVariable(ctx, key, value)
+
+	return true
 }
 
 func setContextVariable(ctx *hcl.EvalContext, key string, value cty.Value) {
@@ -1047,12 +1968,62 @@ func setContextVariableFromPath(ctx *hcl.EvalContext, path string, value cty.Val
 	return err
 }
 
+// getContextVariableFromPath returns the value of the context variable at the given
+// path, path must only contain object attributes
+func getContextVariableFromPath(ctx *hcl.EvalContext, path string) (cty.Value, bool) {
+	parts := strings.Split(path, ".")
+
+	val, ok := ctx.Variables[parts[0]]
+	if !ok {
+		return cty.NilVal, false
+	}
+
+	for _, p := range parts[1:] {
+		if !val.Type().IsObjectType() || !val.Type().HasAttribute(p) {
+			return cty.NilVal, false
+		}
+
+		val = val.GetAttr(p)
+	}
+
+	return val, true
+}
+
 func setMapVariableFromPath(root map[string]cty.Value, path []string, value cty.Value) (map[string]cty.Value, error) {
 	// it is possible for root to be nil, ensure this is set to an empty map
 	if root == nil {
 		root = map[string]cty.Value{}
 	}
 
+	// instance keys i.e. app["api"] are set as nested objects
+	if name, key, ok := strings.Cut(path[0], `["`); ok && strings.HasSuffix(key, `"]`) {
+		key = strings.TrimSuffix(key, `"]`)
+
+		obj := map[string]cty.Value{}
+		if val, ok := root[name]; ok && val.Type().IsObjectType() && val.LengthInt() > 0 {
+			obj = val.AsValueMap()
+		}
+
+		if len(path) == 1 {
+			obj[key] = value
+		} else {
+			child := map[string]cty.Value{}
+			if val, ok := obj[key]; ok && val.Type().IsObjectType() && val.LengthInt() > 0 {
+				child = val.AsValueMap()
+			}
+
+			updated, err := setMapVariableFromPath(child, path[1:], value)
+			if err != nil {
+				return nil, err
+			}
+
+			obj[key] = cty.ObjectVal(updated)
+		}
+
+		root[name] = cty.ObjectVal(obj)
+		return root, nil
+	}
+
 	// gets the name and the index from the path
 	name, index, rPath, err := getNameAndIndex(path)
 	if err != nil {
@@ -1212,7 +2183,7 @@ func getNameAndIndex(path []string) (name string, index int, remainingPath []str
 	return path[0], -1, path[1:], nil
 }
 
-func buildContext(filePath string, customFunctions map[string]function.Function) *hcl.EvalContext {
+func buildContext(fsys fs.FS, filePath string, customFunctions map[string]function.Function) *hcl.EvalContext {
 	ctx := &hcl.EvalContext{
 		Functions: map[string]function.Function{},
 		Variables: map[string]cty.Value{},
@@ -1221,7 +2192,7 @@ func buildContext(filePath string, customFunctions map[string]function.Function)
 	valMap := map[string]cty.Value{}
 	ctx.Variables["resource"] = cty.ObjectVal(valMap)
 
-	ctx.Functions = getDefaultFunctions(filePath)
+	ctx.Functions = getDefaultFunctions(fsys, filePath)
 
 	// add the custom functions
 	for k, v := range customFunctions {
@@ -1231,8 +2202,8 @@ func buildContext(filePath string, customFunctions map[string]function.Function)
 	return ctx
 }
 
-func decodeBody(ctx *hcl.EvalContext, config *Config, path string, b *hclsyntax.Block, p any, ignoreErrors bool) error {
-	dr, err := getDependentResources(b, ctx, config, p, "")
+func decodeBody(ctx *hcl.EvalContext, config *Config, path string, b *hcl.Block, p any, ignoreErrors bool) error {
+	dr, err := getDependentResources(b.Body, ctx, config, p, "")
 	if err != nil {
 		return err
 	}
@@ -1259,10 +2230,10 @@ func decodeBody(ctx *hcl.EvalContext, config *Config, path string, b *hclsyntax.
 		diag := gohcl.DecodeBody(b.Body, ctx, p)
 		if diag.HasErrors() {
 			pe := &errors.ParserError{}
-			pe.Column = b.Body.SrcRange.Start.Column
-			pe.Line = b.Body.SrcRange.Start.Line
-			pe.Filename = b.Body.SrcRange.Filename
-			pe.Message = fmt.Sprintf("unable to decode body, %s", err)
+			pe.Column = b.DefRange.Start.Column
+			pe.Line = b.DefRange.Start.Line
+			pe.Filename = b.DefRange.Filename
+			pe.Message = fmt.Sprintf("unable to decode body, %s", diag.Error())
 			pe.Level = errors.ParserErrorLevelError
 
 			// if ignore errors is false return the parsing error, otherwise
@@ -1284,43 +2255,69 @@ func decodeBody(ctx *hcl.EvalContext, config *Config, path string, b *hclsyntax.
 // i.e. resource.container.foo.network[0].name
 // when a link is found it is replaced with an empty value of the correct type and the
 // dependent resources are returned to be processed later
-func getDependentResources(b *hclsyntax.Block, ctx *hcl.EvalContext, c *Config, resource any, path string) ([]string, error) {
+func getDependentResources(b hcl.Body, ctx *hcl.EvalContext, c *Config, resource any, path string) ([]string, error) {
 	references := []string{}
+	br := bodyRange(b)
 
-	for _, a := range b.Body.Attributes {
-		refs, err := processExpr(a.Expr)
-		if err != nil {
-			pe := &errors.ParserError{}
-			pe.Column = b.Body.SrcRange.Start.Column
-			pe.Line = b.Body.SrcRange.Start.Line
-			pe.Filename = b.Body.SrcRange.Filename
-			pe.Message = fmt.Sprintf("unable to process attribute %s, %s", a.Name, err)
-			pe.Level = errors.ParserErrorLevelError
+	body, ok := b.(*hclsyntax.Body)
+	if !ok {
+		// bodies in the JSON syntax do not have a schema, nested blocks are
+		// returned as attributes containing objects
+		attrs, _ := b.JustAttributes()
+		for _, a := range attrs {
+			refs, err := exprReferences(c.blockTypes, a.Expr)
+			if err != nil {
+				pe := &errors.ParserError{}
+				pe.Column = br.Start.Column
+				pe.Line = br.Start.Line
+				pe.Filename = br.Filename
+				pe.Message = fmt.Sprintf("unable to process attribute %s, %s", a.Name, err)
+				pe.Level = errors.ParserErrorLevelError
+
+				return nil, pe
+			}
 
-			return nil, pe
+			references = append(references, refs...)
 		}
-
-		references = append(references, refs...)
 	}
 
-	// we need to keep a count of the current block so that we
-	// can get this
-	blockIndex := map[string]int{}
-	for _, b := range b.Body.Blocks {
-		if _, ok := blockIndex[b.Type]; ok {
-			blockIndex[b.Type]++
-		} else {
-			blockIndex[b.Type] = 0
-		}
+	if ok {
+		for _, a := range body.Attributes {
+			refs, err := processExpr(c.blockTypes, a.Expr)
+			if err != nil {
+				pe := &errors.ParserError{}
+				pe.Column = br.Start.Column
+				pe.Line = br.Start.Line
+				pe.Filename = br.Filename
+				pe.Message = fmt.Sprintf("unable to process attribute %s, %s", a.Name, err)
+				pe.Level = errors.ParserErrorLevelError
+
+				return nil, pe
+			}
 
-		ref := fmt.Sprintf("%s.%s[%d]", path, b.Type, blockIndex[b.Type])
-		ref = strings.TrimPrefix(ref, ".")
-		cr, err := getDependentResources(b, ctx, c, resource, ref)
-		if err != nil {
-			return nil, err
+			references = append(references, refs...)
 		}
 
-		references = append(references, cr...)
+		// we need to keep a count of the current block so that we
+		// can get this, dynamic blocks are processed like any other block
+		// so references in the for_each and content are found
+		blockIndex := map[string]int{}
+		for _, b := range body.Blocks {
+			if _, ok := blockIndex[b.Type]; ok {
+				blockIndex[b.Type]++
+			} else {
+				blockIndex[b.Type] = 0
+			}
+
+			ref := fmt.Sprintf("%s.%s[%d]", path, b.Type, blockIndex[b.Type])
+			ref = strings.TrimPrefix(ref, ".")
+			cr, err := getDependentResources(b.Body, ctx, c, resource, ref)
+			if err != nil {
+				return nil, err
+			}
+
+			references = append(references, cr...)
+		}
 	}
 
 	me := resource.(types.Resource)
@@ -1336,7 +2333,7 @@ func getDependentResources(b *hclsyntax.Block, ctx *hcl.EvalContext, c *Config,
 			// check the deps on the linked resource
 			for _, cdep := range d.Metadata().Links {
 
-				fqrn, err := resources.ParseFQRN(cdep)
+				fqrn, err := c.blockTypes.ParseFQRN(cdep)
 				fqrn.Attribute = ""
 
 				// append the parent module to the link as they are relative
@@ -1346,9 +2343,9 @@ func getDependentResources(b *hclsyntax.Block, ctx *hcl.EvalContext, c *Config,
 
 				if err != nil {
 					pe := &errors.ParserError{}
-					pe.Column = b.Body.SrcRange.Start.Column
-					pe.Line = b.Body.SrcRange.Start.Line
-					pe.Filename = b.Body.SrcRange.Filename
+					pe.Column = br.Start.Column
+					pe.Line = br.Start.Line
+					pe.Filename = br.Filename
 					pe.Message = fmt.Sprintf("dependency %s, is not a valid resource", cdep)
 					pe.Level = errors.ParserErrorLevelError
 					return nil, pe
@@ -1356,12 +2353,14 @@ func getDependentResources(b *hclsyntax.Block, ctx *hcl.EvalContext, c *Config,
 
 				if me.Metadata().Name == fqrn.Resource &&
 					me.Metadata().Type == fqrn.Type &&
-					me.Metadata().Module == fqrn.Module {
+					me.Metadata().Data == fqrn.Data &&
+					me.Metadata().Module == fqrn.Module &&
+					((fqrn.Index == nil && fqrn.Key == nil) || (equalPtr(me.Metadata().Index, fqrn.Index) && equalPtr(me.Metadata().Key, fqrn.Key))) {
 
 					pe := &errors.ParserError{}
-					pe.Column = b.Body.SrcRange.Start.Column
-					pe.Line = b.Body.SrcRange.Start.Line
-					pe.Filename = b.Body.SrcRange.Filename
+					pe.Column = br.Start.Column
+					pe.Line = br.Start.Line
+					pe.Filename = br.Filename
 					pe.Message = fmt.Sprintf("'%s' depends on '%s' which creates a cyclical dependency, remove the dependency from one of the resources", fqrn.String(), d.Metadata().ID)
 					pe.Level = errors.ParserErrorLevelError
 
@@ -1374,6 +2373,30 @@ func getDependentResources(b *hclsyntax.Block, ctx *hcl.EvalContext, c *Config,
 	return references, nil
 }
 
+// exprReferences returns the references to other resources in the given
+// expression
+func exprReferences(blocks resources.BlockTypes, expr hcl.Expression) ([]string, error) {
+	if ex, ok := expr.(hclsyntax.Expression); ok {
+		return processExpr(blocks, ex)
+	}
+
+	// expressions in the JSON syntax are string templates, the variables
+	// contain all the traversals used in the template
+	references := []string{}
+	for _, t := range expr.Variables() {
+		ref, err := processScopeTraversal(blocks, t)
+		if err != nil {
+			return nil, err
+		}
+
+		if ref != "" {
+			references = append(references, ref)
+		}
+	}
+
+	return references, nil
+}
+
 // processAttribute extracts the necessary data out of the HCL
 // attribute like a function or resource parameter so we can determine
 // which attributes are lazy evaluated due to dependency on another resource.
@@ -1387,7 +2410,7 @@ func getDependentResources(b *hclsyntax.Block, ctx *hcl.EvalContext, c *Config,
 // something = "testing/${resource.mine.attr}"
 // something = "testing/${env(resource.mine.attr)}"
 // something = resource.mine.attr == "abc" ? resource.mine.attr : "abc"
-func processExpr(expr hclsyntax.Expression) ([]string, error) {
+func processExpr(blocks resources.BlockTypes, expr hclsyntax.Expression) ([]string, error) {
 	resources := []string{}
 
 	switch ex := expr.(type) {
@@ -1395,7 +2418,7 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 	// we need to check each part
 	case *hclsyntax.TemplateExpr:
 		for _, v := range ex.Parts {
-			res, err := processExpr(v)
+			res, err := processExpr(blocks, v)
 			if err != nil {
 				return nil, err
 			}
@@ -1403,7 +2426,7 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 			resources = append(resources, res...)
 		}
 	case *hclsyntax.TemplateWrapExpr:
-		res, err := processExpr(ex.Wrapped)
+		res, err := processExpr(blocks, ex.Wrapped)
 		if err != nil {
 			return nil, err
 		}
@@ -1414,7 +2437,7 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 	// myfunction(resource.container.base.name)
 	case *hclsyntax.FunctionCallExpr:
 		for _, v := range ex.Args {
-			res, err := processExpr(v)
+			res, err := processExpr(blocks, v)
 			if err != nil {
 				return nil, err
 			}
@@ -1423,7 +2446,7 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 		}
 	// a function can contain args that may also have an expression
 	case *hclsyntax.ScopeTraversalExpr:
-		ref, err := processScopeTraversal(ex)
+		ref, err := processScopeTraversal(blocks, ex.Traversal)
 		if err != nil {
 			return nil, err
 		}
@@ -1435,7 +2458,7 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 
 	case *hclsyntax.ObjectConsExpr:
 		for _, v := range ex.Items {
-			res, err := processExpr(v.ValueExpr)
+			res, err := processExpr(blocks, v.ValueExpr)
 			if err != nil {
 				return nil, err
 			}
@@ -1444,7 +2467,7 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 		}
 	case *hclsyntax.TupleConsExpr:
 		for _, v := range ex.Exprs {
-			res, err := processExpr(v)
+			res, err := processExpr(blocks, v)
 			if err != nil {
 				return nil, err
 			}
@@ -1454,19 +2477,19 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 	// conditional expressions are like if statements
 	// resource.container.base.name == "hello" ? "this" : "that"
 	case *hclsyntax.ConditionalExpr:
-		conditions, err := processExpr(ex.Condition)
+		conditions, err := processExpr(blocks, ex.Condition)
 		if err != nil {
 			return nil, err
 		}
 		resources = append(resources, conditions...)
 
-		trueResults, err := processExpr(ex.TrueResult)
+		trueResults, err := processExpr(blocks, ex.TrueResult)
 		if err != nil {
 			return nil, err
 		}
 		resources = append(resources, trueResults...)
 
-		falseResults, err := processExpr(ex.FalseResult)
+		falseResults, err := processExpr(blocks, ex.FalseResult)
 		if err != nil {
 			return nil, err
 		}
@@ -1476,19 +2499,19 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 	// resource.container.base.name != "hello"
 	// resource.container.base.name > 3
 	case *hclsyntax.BinaryOpExpr:
-		lhs, err := processExpr(ex.LHS)
+		lhs, err := processExpr(blocks, ex.LHS)
 		if err != nil {
 			return nil, err
 		}
 		resources = append(resources, lhs...)
 
-		rhs, err := processExpr(ex.RHS)
+		rhs, err := processExpr(blocks, ex.RHS)
 		if err != nil {
 			return nil, err
 		}
 		resources = append(resources, rhs...)
 	case *hclsyntax.SplatExpr:
-		ref, err := processExpr(ex.Source)
+		ref, err := processExpr(blocks, ex.Source)
 		if err != nil {
 			return nil, err
 		}
@@ -1498,6 +2521,23 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 			resources = append(resources, ref...)
 		}
 
+	// for expressions iterate over a collection, the key and value symbols
+	// are local to the expression and are ignored as they are not references
+	// [for k, v in resource.container.app : v.meta.id]
+	case *hclsyntax.ForExpr:
+		for _, e := range []hclsyntax.Expression{ex.CollExpr, ex.KeyExpr, ex.ValExpr, ex.CondExpr} {
+			if e == nil {
+				continue
+			}
+
+			res, err := processExpr(blocks, e)
+			if err != nil {
+				return nil, err
+			}
+
+			resources = append(resources, res...)
+		}
+
 		//default:
 		//	pretty.Println(expr)
 	}
@@ -1505,14 +2545,15 @@ func processExpr(expr hclsyntax.Expression) ([]string, error) {
 	return resources, nil
 }
 
-func processScopeTraversal(expr *hclsyntax.ScopeTraversalExpr) (string, error) {
+func processScopeTraversal(blocks resources.BlockTypes, traversal hcl.Traversal) (string, error) {
 	strExpression := ""
-	for i, t := range expr.Traversal {
+	for i, t := range traversal {
 		if i == 0 {
 			strExpression += t.(hcl.TraverseRoot).Name
 
-			// if this is not a resource reference quit
-			if strExpression != "resource" && strExpression != "module" && strExpression != "local" && strExpression != "output" {
+			// if this is not a reference to a block that is processed in the
+			// graph quit, other references are resolved using the context
+			if bt, ok := blocks[strExpression]; !ok || !bt.DAG {
 				return "", nil
 			}
 		} else {
@@ -1536,19 +2577,20 @@ func processScopeTraversal(expr *hclsyntax.ScopeTraversalExpr) (string, error) {
 	return strExpression, nil
 }
 
-func (p *Parser) process(c *Config) error {
+func (p *Parser) process(ctx context.Context, c *Config) error {
 	ce := errors.NewConfigError()
 
 	// process the files and resolve dependency, do this first without any
 	// callbacks so we can calculate the checksum
 	// we are going to ignore the errors at this stage
 	// as there might be interpolation errors
-	c.walk(createCallback(
+	c.walk(ctx, createCallback(
 		c,
 		func(r types.Resource) error {
 			r.Metadata().Checksum.Parsed = generateChecksum(r)
 			return nil
 		},
+		false,
 	), false)
 
 	// variables are not added to the dag so we need to process these
@@ -1556,18 +2598,30 @@ func (p *Parser) process(c *Config) error {
 	vars, err := c.FindResourcesByType(resources.TypeVariable)
 	if err == nil {
 		for _, v := range vars {
-			if p.options.Callback != nil {
-				if err := p.options.Callback(v); err != nil {
-					return err
-				}
+			if err := p.callback(ctx, v); err != nil {
+				return err
 			}
 		}
 	}
 
+	// custom blocks that are not added to the dag have been processed when
+	// they were parsed, only the callbacks need to be called
+	for _, r := range c.Resources {
+		if c.inGraph(r) || r.Metadata().Type == resources.TypeVariable || r.GetDisabled() {
+			continue
+		}
+
+		if err := p.callback(ctx, r); err != nil {
+			return err
+		}
+
+		r.Metadata().Checksum.Processed = generateChecksum(r)
+	}
+
 	// now re-run this time with the callback and the Process function
 	// to calculate a final checksum after any computed properties have been
 	// set
-	errs := c.walk(createCallback(
+	errs := c.walk(ctx, createCallback(
 		c,
 		func(r types.Resource) error {
 			if p, ok := r.(types.Processable); ok {
@@ -1576,21 +2630,27 @@ func (p *Parser) process(c *Config) error {
 				}
 			}
 
-			if p.options.Callback != nil {
-				if err := p.options.Callback(r); err != nil {
-					return err
-				}
+			if err := p.callback(ctx, r); err != nil {
+				return err
 			}
 
 			r.Metadata().Checksum.Processed = generateChecksum(r)
 			return nil
 		},
+		true,
 	), false)
 
 	for _, e := range errs {
 		ce.AppendError(e)
 	}
 
+	// checks are evaluated once all the resources have been processed
+	if len(ce.Errors) == 0 {
+		for _, e := range evaluateChecks(c) {
+			ce.AppendError(e)
+		}
+	}
+
 	if len(ce.Errors) > 0 {
 		return ce
 	}
@@ -1598,6 +2658,23 @@ func (p *Parser) process(c *Config) error {
 	return nil
 }
 
+// callback executes the user defined callbacks for the given resource
+func (p *Parser) callback(ctx context.Context, r types.Resource) error {
+	if p.options.Callback != nil {
+		if err := p.options.Callback(r); err != nil {
+			return err
+		}
+	}
+
+	if p.options.ContextCallback != nil {
+		if err := p.options.ContextCallback(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ensureAbsolute ensure that the given path is either absolute or
 // if relative is converted to abasolute based on the path of the config
 func ensureAbsolute(path, file string) string {
@@ -1626,6 +2703,17 @@ func ensureAbsolute(path, file string) string {
 	return filepath.Clean(fp)
 }
 
+// validateInstanceKey checks that the key for an instance created with for_each
+// only contains characters that are valid in a resource name
+func validateInstanceKey(key string) error {
+	r, _ := regexp.Compile(`^[0-9a-zA-Z_-]+$`)
+	if !r.MatchString(key) {
+		return fmt.Errorf(`invalid for_each key "%s", keys can only contain the characters 0-9 a-z A-Z _ -`, key)
+	}
+
+	return nil
+}
+
 func validateResourceName(name string) error {
 	if name == "resource" || name == "module" || name == "output" || name == "variable" {
 		return fmt.Errorf("invalid resource name %s, resources can not use the reserved names [resource, module, output, variable]", name)
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,6 +14,33 @@ type Registry interface {
 	GetModule(organization string, name string, version string) (*Module, error)
 }
 
+// ContextRegistry defines an optional interface that allows a Registry to
+// cancel requests when the given context is done
+type ContextRegistry interface {
+	GetModuleVersionsWithContext(ctx context.Context, organization string, module string) (*Versions, error)
+	GetModuleWithContext(ctx context.Context, organization string, name string, version string) (*Module, error)
+}
+
+// GetModuleVersionsWithContext returns the versions of the module using the
+// context when the registry implements ContextRegistry
+func GetModuleVersionsWithContext(ctx context.Context, r Registry, organization string, module string) (*Versions, error) {
+	if cr, ok := r.(ContextRegistry); ok {
+		return cr.GetModuleVersionsWithContext(ctx, organization, module)
+	}
+
+	return r.GetModuleVersions(organization, module)
+}
+
+// GetModuleWithContext returns the module using the context when the registry
+// implements ContextRegistry
+func GetModuleWithContext(ctx context.Context, r Registry, organization string, name string, version string) (*Module, error) {
+	if cr, ok := r.(ContextRegistry); ok {
+		return cr.GetModuleWithContext(ctx, organization, name, version)
+	}
+
+	return r.GetModule(organization, name, version)
+}
+
 type TransportWithCredentials struct {
 	token string
 	T     http.RoundTripper
@@ -63,6 +91,12 @@ type Version struct {
 }
 
 func New(host string, token string) (Registry, error) {
+	return NewWithContext(context.Background(), host, token)
+}
+
+// NewWithContext creates a registry client, the request to discover the
+// capabilities of the registry is cancelled when the given context is done
+func NewWithContext(ctx context.Context, host string, token string) (Registry, error) {
 	client := http.Client{
 		Timeout: 5 * time.Second,
 		Transport: &TransportWithCredentials{
@@ -71,13 +105,19 @@ func New(host string, token string) (Registry, error) {
 		},
 	}
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/.well-known/registry.json", host), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/.well-known/registry.json", host), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
+		// the request failed because the context was cancelled, not because
+		// the host is not a registry
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		return nil, fmt.Errorf(`"%s" is not a valid registry`, host)
 	}
 
@@ -109,7 +149,11 @@ func New(host string, token string) (Registry, error) {
 }
 
 func (r *RegistryImpl) GetModuleVersions(organization string, module string) (*Versions, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/%s/%s/versions", r.Modules, organization, module), nil)
+	return r.GetModuleVersionsWithContext(context.Background(), organization, module)
+}
+
+func (r *RegistryImpl) GetModuleVersionsWithContext(ctx context.Context, organization string, module string) (*Versions, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/%s/%s/versions", r.Modules, organization, module), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -129,7 +173,11 @@ func (r *RegistryImpl) GetModuleVersions(organization string, module string) (*V
 }
 
 func (r *RegistryImpl) GetModule(organization string, name string, version string) (*Module, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/%s/%s/%s", r.Modules, organization, name, version), nil)
+	return r.GetModuleWithContext(context.Background(), organization, name, version)
+}
+
+func (r *RegistryImpl) GetModuleWithContext(ctx context.Context, organization string, name string, version string) (*Module, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/%s/%s/%s", r.Modules, organization, name, version), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,106 @@
+package resources
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jumppad-labs/hclconfig/types"
+)
+
+// BlockType defines a top level block that can be used in the configuration
+// i.e. provider "docker" {}
+type BlockType struct {
+	// Name is the keyword of the block, references to blocks of this type
+	// start with the keyword i.e. provider.docker.version
+	Name string
+
+	// Labels are the names of the labels of the block, the labels form the
+	// name of the resource, blocks with more than one label are referenced
+	// using all the labels i.e. pipeline.build.test
+	Labels []string
+
+	// Resource is the Go type that the block is decoded into, the type is nil
+	// for the resource and data blocks where the first label is the type
+	Resource types.Resource
+
+	// DAG determines if the block is processed when the graph is walked, blocks
+	// that take part in the graph can reference any other resource. Blocks that
+	// do not are decoded when the configuration is parsed and can only reference
+	// variables, like variables their values are available to all resources.
+	DAG bool
+}
+
+// BlockTypes contains the top level blocks keyed by the keyword of the block
+type BlockTypes map[string]BlockType
+
+// DefaultBlockTypes returns the top level blocks that are defined by hclconfig
+func DefaultBlockTypes() BlockTypes {
+	return BlockTypes{
+		types.TypeResource: {Name: types.TypeResource, Labels: []string{"type", "name"}, DAG: true},
+		types.TypeData:     {Name: types.TypeData, Labels: []string{"type", "name"}, DAG: true},
+		TypeModule:         {Name: TypeModule, Labels: []string{"name"}, Resource: &Module{}, DAG: true},
+		TypeOutput:         {Name: TypeOutput, Labels: []string{"name"}, Resource: &Output{}, DAG: true},
+		TypeLocal:          {Name: TypeLocal, Labels: []string{"name"}, Resource: &Local{}, DAG: true},
+		TypeCheck:          {Name: TypeCheck, Labels: []string{"name"}, Resource: &Check{}, DAG: true},
+		TypeVariable:       {Name: TypeVariable, Labels: []string{"name"}, Resource: &Variable{}, DAG: false},
+	}
+}
+
+// defaultBlockTypes is used by ParseFQRN and FQRNFromResource, it must not be
+// modified
+var defaultBlockTypes = DefaultBlockTypes()
+
+var blockNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Register adds a top level block, the keyword of the block is used by
+// ParseFQRN to parse references to resources defined by the block. An error
+// is returned when the keyword is already used by another block.
+func (b BlockTypes) Register(bt BlockType) error {
+	if !blockNameRegex.MatchString(bt.Name) {
+		return fmt.Errorf(`invalid block type "%s", the name can only contain the characters a-z 0-9 _ and must start with a letter`, bt.Name)
+	}
+
+	if len(bt.Labels) == 0 {
+		return fmt.Errorf(`invalid block type "%s", blocks must have at least one label`, bt.Name)
+	}
+
+	if bt.Resource == nil {
+		return fmt.Errorf(`invalid block type "%s", a resource type must be provided`, bt.Name)
+	}
+
+	if _, ok := b[bt.Name]; ok {
+		return fmt.Errorf(`block type "%s" has already been registered`, bt.Name)
+	}
+
+	b[bt.Name] = bt
+
+	return nil
+}
+
+// Sorted returns the blocks sorted by their keyword
+func (b BlockTypes) Sorted() []BlockType {
+	bts := []BlockType{}
+	for _, bt := range b {
+		bts = append(bts, bt)
+	}
+
+	slices.SortFunc(bts, func(a, b BlockType) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return bts
+}
+
+// custom returns the block with the given keyword when the block has been
+// registered, false is returned for the blocks defined by hclconfig
+func (b BlockTypes) custom(name string) (BlockType, bool) {
+	if _, ok := defaultBlockTypes[name]; ok {
+		return BlockType{}, false
+	}
+
+	bt, ok := b[name]
+
+	return bt, ok
+}
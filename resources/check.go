@@ -0,0 +1,39 @@
+package resources
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/jumppad-labs/hclconfig/types"
+)
+
+const TypeCheck = "check"
+
+const (
+	// CheckSeverityError causes a failing check to be reported as an error
+	CheckSeverityError = "error"
+	// CheckSeverityWarning causes a failing check to be reported as a warning
+	CheckSeverityWarning = "warning"
+)
+
+// Check defines assertions about the configuration, checks are evaluated once
+// all the resources have been processed
+type Check struct {
+	types.ResourceBase `hcl:",remain"`
+
+	// Severity determines if a failing assertion is reported as an "error" or
+	// a "warning", defaults to error
+	Severity string `hcl:"severity,optional" json:"severity,omitempty" description:"error or warning, determines how failing assertions are reported"`
+
+	// Asserts are the conditions that must be true
+	Asserts []Assert `hcl:"assert,block" json:"-" description:"conditions that must be true"`
+
+	// Failures contains the error messages of the assertions that failed, this
+	// is set by the parser when the check is evaluated
+	Failures []string `json:"failures,omitempty"`
+}
+
+// Assert defines a condition for a check, the error message is returned when
+// the condition is false
+type Assert struct {
+	Condition    hcl.Expression `hcl:"condition" json:"-" description:"expression that must be true"`
+	ErrorMessage hcl.Expression `hcl:"error_message" json:"-" description:"message returned when the condition is false"`
+}
@@ -8,6 +8,7 @@ func DefaultResources() types.RegisteredTypes {
 		"variable": &Variable{},
 		"output":   &Output{},
 		"local":    &Local{},
+		"check":    &Check{},
 		"module":   &Module{},
 		"root":     &Root{},
 	}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/jumppad-labs/hclconfig/types"
@@ -17,6 +19,17 @@ type FQRN struct {
 	Type string
 	// Resource name
 	Resource string
+	// Data is true when the FQRN refers to a data source i.e. data.type.name
+	Data bool
+	// Block is true when the FQRN refers to a resource defined by a registered
+	// block, the type is the keyword of the block i.e. provider.docker
+	Block bool
+	// Index of the resource instance when the resource has been expanded
+	// with count, nil when the FQRN does not refer to an instance
+	Index *int
+	// Key of the resource instance when the resource has been expanded
+	// with for_each, nil when the FQRN does not refer to an instance
+	Key *string
 	// Attribute for the resource
 	Attribute string
 }
@@ -31,12 +44,26 @@ type FQRN struct {
 // get the "resource" container called mine that is in the root "module"
 // // resource.container.mine
 //
+// get the instance at index 2 of the "resource" container called mine that
+// has been expanded with count
+// // resource.container.mine[2]
+//
+// get the instance with the key "api" of the "resource" container called mine
+// that has been expanded with for_each
+// // resource.container.mine["api"]
+//
+// get the "data" source container called mine that is in the root "module"
+// // data.container.mine
+//
 // get the "output" called mine that is in the root "module"
 // // output.mine
 //
 // get the "local" called mine that is in the root "module"
 // // local.mine
 //
+// get the "check" called mine that is in the root "module"
+// // check.mine
+//
 // get the container "resource" called mine in the "module" module2 that
 // is in the "module" module1
 // // module1.module2.resource.container.mine
@@ -50,35 +77,59 @@ type FQRN struct {
 //
 // get the "module" resource called module1 in the root "module"
 // // module1
+//
+// get the instance with the key "api" of the "module" resource called module1
+// // module.module1["api"]
 func ParseFQRN(fqrn string) (*FQRN, error) {
+	return defaultBlockTypes.ParseFQRN(fqrn)
+}
+
+// ParseFQRN parses a fqrn in the same way as the package function ParseFQRN,
+// references to the registered blocks i.e. provider.docker are also parsed
+func (b BlockTypes) ParseFQRN(fqrn string) (*FQRN, error) {
 	moduleName := ""
 	typeName := ""
 	resourceName := ""
 	attribute := ""
+	data := false
+	block := false
+	var index *int
+	var key *string
+
+	// first split on the module and the keyword of the block i.e. resource or output
+	modules, ref := b.splitModules(fqrn)
+	results := map[string]string{"modules": modules}
 
-	// first split on the resource, module, or output
-	r := regexp.MustCompile(`^(module.(?P<modules>.*)\.)?(?:(?P<resource>(resource|output|local|variable))\.(?P<attributes>(.*)))|(?P<onlymodules>.*)`)
-	match := r.FindStringSubmatch(fqrn)
-	results := map[string]string{}
+	r := regexp.MustCompile(fmt.Sprintf(`^(?P<resource>(%s))\.(?P<attributes>(.*))$`, b.keywordPattern()))
+	match := r.FindStringSubmatch(ref)
 	for i, name := range match {
 		results[r.SubexpNames()[i]] = name
 	}
 
-	if len(results) < 2 {
-		return nil, errors.New(formatErrorString(fqrn))
+	// references that do not contain a keyword are references to modules
+	if match == nil {
+		results["onlymodules"] = fqrn
 	}
 
 	switch results["resource"] {
-	case "resource":
+	case "resource", "data":
 		resourceParts := strings.Split(results["attributes"], ".")
 		if len(resourceParts) < 2 {
 			return nil, errors.New(formatErrorString(fqrn))
 		}
 
+		name, idx, k, err := parseInstance(resourceParts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", formatErrorString(fqrn), err)
+		}
+
 		typeName = resourceParts[0]
-		resourceName = resourceParts[1]
+		resourceName = name
+		index = idx
+		key = k
 		attribute = strings.Join(resourceParts[2:], ".")
 		moduleName = results["modules"]
+		data = results["resource"] == "data"
 
 	case "local":
 		fallthrough
@@ -115,7 +166,17 @@ func ParseFQRN(fqrn string) (*FQRN, error) {
 		resourceName = varParts[0]
 		moduleName = results["modules"]
 
-	default:
+	case "check":
+		checkParts := strings.Split(results["attributes"], ".")
+		if len(checkParts) != 1 {
+			return nil, errors.New(formatErrorString(fqrn))
+		}
+
+		typeName = TypeCheck
+		resourceName = checkParts[0]
+		moduleName = results["modules"]
+
+	case "":
 		if results["onlymodules"] == "" || !strings.HasPrefix(results["onlymodules"], "module.") {
 			return nil, errors.New(formatErrorString(fqrn))
 		}
@@ -123,26 +184,154 @@ func ParseFQRN(fqrn string) (*FQRN, error) {
 		//module1.module2
 		modules := strings.Split(results["onlymodules"], ".")
 
-		if len(modules) == 2 {
-			resourceName = modules[1]
-		} else {
+		if len(modules) > 2 {
 			moduleName = strings.Join(modules[1:len(modules)-1], ".")
-			resourceName = modules[len(modules)-1]
 		}
 
+		name, idx, k, err := parseInstance(modules[len(modules)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", formatErrorString(fqrn), err)
+		}
+
+		resourceName = name
+		index = idx
+		key = k
 		typeName = TypeModule
+
+	default:
+		// blocks registered with RegisterBlockType are referenced using the
+		// keyword followed by the labels i.e. provider.docker
+		bt, ok := b.custom(results["resource"])
+		if !ok {
+			return nil, errors.New(formatErrorString(fqrn))
+		}
+
+		parts := strings.Split(results["attributes"], ".")
+		if len(parts) < len(bt.Labels) {
+			return nil, errors.New(formatErrorString(fqrn))
+		}
+
+		typeName = bt.Name
+		resourceName = strings.Join(parts[:len(bt.Labels)], ".")
+		attribute = strings.Join(parts[len(bt.Labels):], ".")
+		moduleName = results["modules"]
+		block = true
 	}
 
 	return &FQRN{
 		Module:    moduleName,
 		Type:      typeName,
 		Resource:  resourceName,
+		Data:      data,
+		Block:     block,
+		Index:     index,
+		Key:       key,
 		Attribute: attribute,
 	}, nil
 }
 
+// parseInstance splits a resource name that contains an instance index
+// i.e. mine[2] or an instance key i.e. mine["api"] into its parts
+func parseInstance(name string) (string, *int, *string, error) {
+	n, idx, ok := strings.Cut(name, "[")
+	if !ok {
+		return name, nil, nil, nil
+	}
+
+	if !strings.HasSuffix(idx, "]") {
+		return "", nil, nil, fmt.Errorf("invalid instance index %s", name)
+	}
+
+	idx = strings.TrimSuffix(idx, "]")
+
+	// keys are quoted strings
+	if strings.HasPrefix(idx, `"`) {
+		if len(idx) < 2 || !strings.HasSuffix(idx, `"`) {
+			return "", nil, nil, fmt.Errorf("invalid instance key %s", name)
+		}
+
+		k := idx[1 : len(idx)-1]
+		return n, nil, &k, nil
+	}
+
+	i, err := strconv.Atoi(idx)
+	if err != nil || i < 0 {
+		return "", nil, nil, fmt.Errorf("instance index %s is not a positive number", name)
+	}
+
+	return n, &i, nil, nil
+}
+
+// splitModules splits a reference into the path of the modules and the
+// reference to the resource in the module i.e. module.module1.module2.output.mine
+// returns module1.module2 and output.mine.
+//
+// Module names can be the same as the keyword of a block, the module path
+// ends at the first keyword that is followed by a name that is not a keyword
+// i.e. module.data.data.container.mine is the data source container.mine in the
+// module data. When every keyword is followed by another keyword the first
+// keyword is used i.e. module.mine.output.local is the output local.
+func (b BlockTypes) splitModules(fqrn string) (string, string) {
+	path, ok := strings.CutPrefix(fqrn, "module.")
+	if !ok {
+		return "", fqrn
+	}
+
+	keywords := b.blockKeywords()
+	parts := strings.Split(path, ".")
+
+	split := -1
+
+	// the first part is always the name of a module, the keyword must be
+	// followed by at least one name
+	for i := 1; i < len(parts)-1; i++ {
+		if !slices.Contains(keywords, parts[i]) {
+			continue
+		}
+
+		if split == -1 {
+			split = i
+		}
+
+		if !slices.Contains(keywords, parts[i+1]) {
+			split = i
+			break
+		}
+	}
+
+	if split == -1 {
+		return "", ""
+	}
+
+	return strings.Join(parts[:split], "."), strings.Join(parts[split:], ".")
+}
+
+// blockKeywords returns the keywords of the top level blocks that are used to
+// reference resources, modules are referenced without a keyword
+func (b BlockTypes) blockKeywords() []string {
+	keywords := []string{}
+	for _, bt := range b.Sorted() {
+		if bt.Name != TypeModule {
+			keywords = append(keywords, bt.Name)
+		}
+	}
+
+	return keywords
+}
+
+// keywordPattern returns a regular expression matching the keywords of the top
+// level blocks
+func (b BlockTypes) keywordPattern() string {
+	keywords := []string{}
+	for _, k := range b.blockKeywords() {
+		keywords = append(keywords, regexp.QuoteMeta(k))
+	}
+
+	return strings.Join(keywords, "|")
+}
+
 func formatErrorString(fqdn string) string {
-	return fmt.Sprintf("ParseFQRN expects the fqdn to be formatted as variable.name, local.name, output.name, resource.type.name, module.module1.module2, or module.module1.module2.resource.type.name. The fqrn: %s, does not contain a resource type", fqdn)
+	return fmt.Sprintf("ParseFQRN expects the fqdn to be formatted as variable.name, local.name, output.name, check.name, resource.type.name, data.type.name, module.module1.module2, or module.module1.module2.resource.type.name. The fqrn: %s, does not contain a resource type", fqdn)
 }
 
 // AppendParentModule creates a new FQRN by adding the parent module
@@ -157,6 +346,10 @@ func (f *FQRN) AppendParentModule(parent string) FQRN {
 	}
 
 	newFQRN.Resource = f.Resource
+	newFQRN.Data = f.Data
+	newFQRN.Block = f.Block
+	newFQRN.Index = f.Index
+	newFQRN.Key = f.Key
 	newFQRN.Type = f.Type
 	newFQRN.Attribute = f.Attribute
 
@@ -165,9 +358,21 @@ func (f *FQRN) AppendParentModule(parent string) FQRN {
 
 // FQRNFromResource returns the ResourceFQDN for the given Resource
 func FQRNFromResource(r types.Resource) *FQRN {
+	return defaultBlockTypes.FQRNFromResource(r)
+}
+
+// FQRNFromResource returns the ResourceFQDN for the given Resource, resources
+// defined by the registered blocks are referenced using the keyword of the block
+func (b BlockTypes) FQRNFromResource(r types.Resource) *FQRN {
+	_, block := b.custom(r.Metadata().Type)
+
 	return &FQRN{
 		Module:   r.Metadata().Module,
 		Resource: r.Metadata().Name,
+		Data:     r.Metadata().Data,
+		Block:    block && !r.Metadata().Data,
+		Index:    r.Metadata().Index,
+		Key:      r.Metadata().Key,
 		Type:     r.Metadata().Type,
 	}
 }
@@ -183,19 +388,19 @@ func (f FQRN) String() string {
 		attrPart = fmt.Sprintf(".%s", f.Attribute)
 	}
 
-	if f.Type == TypeOutput || f.Type == TypeLocal || f.Type == TypeVariable {
-		return fmt.Sprintf("%s%s.%s%s", modulePart, f.Type, f.Resource, attrPart)
-	}
-
 	if f.Type == TypeModule {
 		if f.Module == "" {
-			return fmt.Sprintf("module.%s", f.Resource)
+			return fmt.Sprintf("module.%s%s", f.Resource, f.instancePart())
 		}
 
-		return fmt.Sprintf("%s%s", modulePart, f.Resource)
+		return fmt.Sprintf("%s%s%s", modulePart, f.Resource, f.instancePart())
+	}
+
+	if f.named() {
+		return fmt.Sprintf("%s%s.%s%s", modulePart, f.Type, f.Resource, attrPart)
 	}
 
-	return fmt.Sprintf("%sresource.%s.%s%s", modulePart, f.Type, f.Resource, attrPart)
+	return fmt.Sprintf("%s%s.%s.%s%s%s", modulePart, f.blockType(), f.Type, f.Resource, f.instancePart(), attrPart)
 }
 
 func (f FQRN) StringWithoutAttribute() string {
@@ -204,17 +409,56 @@ func (f FQRN) StringWithoutAttribute() string {
 		modulePart = fmt.Sprintf("module.%s.", f.Module)
 	}
 
-	if f.Type == TypeOutput || f.Type == TypeLocal || f.Type == TypeVariable {
-		return fmt.Sprintf("%s%s.%s", modulePart, f.Type, f.Resource)
-	}
-
 	if f.Type == TypeModule {
 		if f.Module == "" {
-			return fmt.Sprintf("module.%s", f.Resource)
+			return fmt.Sprintf("module.%s%s", f.Resource, f.instancePart())
 		}
 
-		return fmt.Sprintf("%s%s", modulePart, f.Resource)
+		return fmt.Sprintf("%s%s%s", modulePart, f.Resource, f.instancePart())
+	}
+
+	if f.named() {
+		return fmt.Sprintf("%s%s.%s", modulePart, f.Type, f.Resource)
+	}
+
+	return fmt.Sprintf("%s%s.%s.%s%s", modulePart, f.blockType(), f.Type, f.Resource, f.instancePart())
+}
+
+// named returns true when the resource is referenced using the keyword of the
+// block followed by the name i.e. output.mine
+func (f FQRN) named() bool {
+	switch f.Type {
+	case TypeOutput, TypeLocal, TypeVariable, TypeCheck:
+		return true
+	}
+
+	return f.Block
+}
+
+// blockType returns the type of the block that defines the resource
+func (f FQRN) blockType() string {
+	if f.Data {
+		return types.TypeData
+	}
+
+	return types.TypeResource
+}
+
+// instancePart returns the index or key selector for resource instances
+func (f FQRN) instancePart() string {
+	return InstanceSelector(f.Index, f.Key)
+}
+
+// InstanceSelector returns the selector that is appended to the name of a
+// resource instance, [2] for an index or ["api"] for a key
+func InstanceSelector(index *int, key *string) string {
+	if index != nil {
+		return fmt.Sprintf("[%d]", *index)
+	}
+
+	if key != nil {
+		return fmt.Sprintf(`["%s"]`, *key)
 	}
 
-	return fmt.Sprintf("%sresource.%s.%s", modulePart, f.Type, f.Resource)
+	return ""
 }
@@ -180,6 +180,128 @@ func TestParseFQRNReturnsLocal(t *testing.T) {
 	require.Equal(t, "local.mine", sfrqn)
 }
 
+func TestParseFQRNReturnsCheckInModule(t *testing.T) {
+	fqrn, err := ParseFQRN("module.mymodule.check.mine")
+	require.NoError(t, err)
+
+	require.Equal(t, "mymodule", fqrn.Module)
+	require.Equal(t, TypeCheck, fqrn.Type)
+	require.Equal(t, "mine", fqrn.Resource)
+	require.Equal(t, "", fqrn.Attribute)
+
+	sfrqn := fqrn.String()
+	require.Equal(t, "module.mymodule.check.mine", sfrqn)
+}
+
+func TestParseFQRNReturnsDataSource(t *testing.T) {
+	fqrn, err := ParseFQRN("data.container.mine.property.value")
+	require.NoError(t, err)
+
+	require.Equal(t, "", fqrn.Module)
+	require.Equal(t, typeTestContainer, fqrn.Type)
+	require.Equal(t, "mine", fqrn.Resource)
+	require.True(t, fqrn.Data)
+	require.Equal(t, "property.value", fqrn.Attribute)
+
+	sfrqn := fqrn.String()
+	require.Equal(t, "data.container.mine.property.value", sfrqn)
+}
+
+func TestParseFQRNReturnsDataSourceInModuleNamedData(t *testing.T) {
+	fqrn, err := ParseFQRN(`module.data.data.container.mine["api"].data`)
+	require.NoError(t, err)
+
+	require.Equal(t, "data", fqrn.Module)
+	require.Equal(t, typeTestContainer, fqrn.Type)
+	require.Equal(t, "mine", fqrn.Resource)
+	require.Equal(t, "api", *fqrn.Key)
+	require.True(t, fqrn.Data)
+	require.Equal(t, "data", fqrn.Attribute)
+
+	sfrqn := fqrn.StringWithoutAttribute()
+	require.Equal(t, `module.data.data.container.mine["api"]`, sfrqn)
+}
+
+func TestParseFQRNReturnsResourceInNestedModuleNamedData(t *testing.T) {
+	fqrn, err := ParseFQRN("module.mymodule.data.resource.container.mine.output.value")
+	require.NoError(t, err)
+
+	require.Equal(t, "mymodule.data", fqrn.Module)
+	require.Equal(t, typeTestContainer, fqrn.Type)
+	require.Equal(t, "mine", fqrn.Resource)
+	require.False(t, fqrn.Data)
+	require.Equal(t, "output.value", fqrn.Attribute)
+
+	sfrqn := fqrn.String()
+	require.Equal(t, "module.mymodule.data.resource.container.mine.output.value", sfrqn)
+}
+
+func TestParseFQRNReturnsResourceInNestedModuleNamedOutput(t *testing.T) {
+	fqrn, err := ParseFQRN("module.mymodule.output.resource.container.mine")
+	require.NoError(t, err)
+
+	require.Equal(t, "mymodule.output", fqrn.Module)
+	require.Equal(t, typeTestContainer, fqrn.Type)
+	require.Equal(t, "mine", fqrn.Resource)
+	require.Equal(t, "", fqrn.Attribute)
+}
+
+func TestParseFQRNReturnsResourceWithAttributeContainingKeyword(t *testing.T) {
+	fqrn, err := ParseFQRN("module.mymodule.resource.container.mine.output.local")
+	require.NoError(t, err)
+
+	require.Equal(t, "mymodule", fqrn.Module)
+	require.Equal(t, typeTestContainer, fqrn.Type)
+	require.Equal(t, "mine", fqrn.Resource)
+	require.Equal(t, "output.local", fqrn.Attribute)
+}
+
+func TestParseFQRNReturnsOutputNamedLocalInModule(t *testing.T) {
+	fqrn, err := ParseFQRN("module.mymodule.output.local")
+	require.NoError(t, err)
+
+	require.Equal(t, "mymodule", fqrn.Module)
+	require.Equal(t, TypeOutput, fqrn.Type)
+	require.Equal(t, "local", fqrn.Resource)
+}
+
+func TestParseFQRNReturnsNestedModuleNamedData(t *testing.T) {
+	fqrn, err := ParseFQRN("module.mymodule.data")
+	require.NoError(t, err)
+
+	require.Equal(t, "mymodule", fqrn.Module)
+	require.Equal(t, TypeModule, fqrn.Type)
+	require.Equal(t, "data", fqrn.Resource)
+
+	sfrqn := fqrn.String()
+	require.Equal(t, "module.mymodule.data", sfrqn)
+}
+
+func TestParseFQRNReturnsRegisteredBlockType(t *testing.T) {
+	bt := DefaultBlockTypes()
+	err := bt.Register(BlockType{Name: "pipeline", Labels: []string{"type", "name"}, Resource: &Output{}, DAG: true})
+	require.NoError(t, err)
+
+	fqrn, err := bt.ParseFQRN("module.mymodule.pipeline.build.app.steps")
+	require.NoError(t, err)
+
+	require.Equal(t, "mymodule", fqrn.Module)
+	require.Equal(t, "pipeline", fqrn.Type)
+	require.Equal(t, "build.app", fqrn.Resource)
+	require.Equal(t, "steps", fqrn.Attribute)
+	require.True(t, fqrn.Block)
+
+	sfrqn := fqrn.String()
+	require.Equal(t, "module.mymodule.pipeline.build.app.steps", sfrqn)
+
+	_, err = bt.ParseFQRN("pipeline.build")
+	require.Error(t, err)
+
+	// blocks are only parsed by the block types they are registered with
+	_, err = ParseFQRN("pipeline.build.app")
+	require.Error(t, err)
+}
+
 func TestParseResourceFQRNWithIndexReturnsCorrectData(t *testing.T) {
 	fqrn, err := ParseFQRN("resource.container.mine.property.0")
 	require.NoError(t, err)
@@ -245,6 +367,55 @@ func TestParseFQRNWithIndexAndModuleReturnsCorrectData(t *testing.T) {
 	require.Equal(t, "module.mine.output.mine.name", sfrqn)
 }
 
+func TestParseFQRNWithInstanceIndexReturnsCorrectData(t *testing.T) {
+	fqrn, err := ParseFQRN("module.mine.resource.container.worker[2].meta.id")
+	require.NoError(t, err)
+
+	require.Equal(t, "mine", fqrn.Module)
+	require.Equal(t, typeTestContainer, fqrn.Type)
+	require.Equal(t, "worker", fqrn.Resource)
+	require.Equal(t, 2, *fqrn.Index)
+	require.Equal(t, "meta.id", fqrn.Attribute)
+
+	require.Equal(t, "module.mine.resource.container.worker[2].meta.id", fqrn.String())
+	require.Equal(t, "module.mine.resource.container.worker[2]", fqrn.StringWithoutAttribute())
+}
+
+func TestParseFQRNWithInvalidInstanceIndexReturnsError(t *testing.T) {
+	_, err := ParseFQRN("resource.container.worker[two]")
+	require.Error(t, err)
+
+	_, err = ParseFQRN("resource.container.worker[-1]")
+	require.Error(t, err)
+}
+
+func TestParseFQRNWithInstanceKeyReturnsCorrectData(t *testing.T) {
+	fqrn, err := ParseFQRN(`module.mine.resource.container.app["api"].meta.id`)
+	require.NoError(t, err)
+
+	require.Equal(t, "mine", fqrn.Module)
+	require.Equal(t, typeTestContainer, fqrn.Type)
+	require.Equal(t, "app", fqrn.Resource)
+	require.Nil(t, fqrn.Index)
+	require.Equal(t, "api", *fqrn.Key)
+	require.Equal(t, "meta.id", fqrn.Attribute)
+
+	require.Equal(t, `module.mine.resource.container.app["api"].meta.id`, fqrn.String())
+	require.Equal(t, `module.mine.resource.container.app["api"]`, fqrn.StringWithoutAttribute())
+}
+
+func TestParseFQRNWithModuleInstanceKeyReturnsCorrectData(t *testing.T) {
+	fqrn, err := ParseFQRN(`module.parent.env["web"]`)
+	require.NoError(t, err)
+
+	require.Equal(t, "parent", fqrn.Module)
+	require.Equal(t, TypeModule, fqrn.Type)
+	require.Equal(t, "env", fqrn.Resource)
+	require.Equal(t, "web", *fqrn.Key)
+
+	require.Equal(t, `module.parent.env["web"]`, fqrn.String())
+}
+
 func TestFQRNFromResourceReturnsCorrectData(t *testing.T) {
 	dt := DefaultResources()
 	dt[typeTestContainer] = &testContainer{}
@@ -264,6 +435,21 @@ func TestFQRNFromResourceReturnsCorrectData(t *testing.T) {
 	require.Equal(t, "module.mymodule.resource.container.mytest", sfrqn)
 }
 
+func TestFQRNFromResourceWithIndexReturnsCorrectData(t *testing.T) {
+	dt := DefaultResources()
+	dt[typeTestContainer] = &testContainer{}
+
+	r, err := dt.CreateResource(typeTestContainer, "worker")
+	require.NoError(t, err)
+
+	index := 1
+	r.Metadata().Index = &index
+
+	fqrn := FQRNFromResource(r)
+	require.Equal(t, 1, *fqrn.Index)
+	require.Equal(t, "resource.container.worker[1]", fqrn.String())
+}
+
 func TestFQRNFromVariableReturnsCorrectData(t *testing.T) {
 	dt := DefaultResources()
 
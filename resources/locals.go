@@ -11,6 +11,6 @@ const TypeLocal = "local"
 type Local struct {
 	types.ResourceBase `hcl:",remain"`
 
-	CtyValue cty.Value `hcl:"value,optional"` // value of the output
+	CtyValue cty.Value `hcl:"value,optional" description:"value of the local"`
 	Value    any       `json:"value"`
 }
@@ -13,10 +13,10 @@ const TypeModule = "module"
 type Module struct {
 	types.ResourceBase `hcl:",remain"`
 
-	Source  string `hcl:"source" json:"source"`
-	Version string `hcl:"version,optional" json:"version,omitempty"`
+	Source  string `hcl:"source" json:"source" description:"local folder or remote address of the module"`
+	Version string `hcl:"version,optional" json:"version,omitempty" description:"version of the module when the source is a registry"`
 
-	Variables any `hcl:"variables,optional" json:"variables,omitempty"`
+	Variables any `hcl:"variables,optional" json:"variables,omitempty" description:"values for the variables of the module"`
 
 	// SubContext is used to store the variables as a context that can be
 	// passed to child resources
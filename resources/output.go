@@ -11,7 +11,12 @@ const TypeOutput = "output"
 type Output struct {
 	types.ResourceBase `hcl:",remain"`
 
-	CtyValue    cty.Value `hcl:"value,optional"` // value of the output
+	CtyValue    cty.Value `hcl:"value,optional" description:"value of the output"`
 	Value       any       `json:"value"`
-	Description string    `hcl:"description,optional" json:"description,omitempty"` // description for the output
+	Description string    `hcl:"description,optional" json:"description,omitempty" description:"description of the output"`
+
+	// Sensitive is set when the output has been marked as sensitive or when the
+	// value contains a sensitive value, Value is redacted when the config is
+	// serialized with ToJSON
+	Sensitive bool `hcl:"sensitive,optional" json:"sensitive,omitempty" description:"the value of the output is redacted"`
 }
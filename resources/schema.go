@@ -0,0 +1,344 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jumppad-labs/hclconfig/types"
+)
+
+// Schema defines a resource type without a Go struct, the attributes and
+// blocks of the resource are defined by the fields of the schema
+type Schema struct {
+	// Type is the type of the resource i.e. person
+	Type string `hcl:"type,label" json:"type"`
+
+	// Description describes the resource type
+	Description string `hcl:"description,optional" json:"description,omitempty"`
+
+	Fields []*SchemaField `hcl:"field,block" json:"fields"`
+}
+
+// SchemaField defines an attribute or a nested block of a resource type that
+// has been defined by a schema
+type SchemaField struct {
+	Name string `hcl:"name,label" json:"name"`
+
+	// Type is the type of the field, attributes have the type string, int, bool,
+	// list(type) or map(type) i.e. list(string). Nested blocks have the type
+	// block for a single block or list(block) for any number of blocks, the
+	// fields of a nested block are defined by Fields.
+	Type string `hcl:"type" json:"type"`
+
+	// Description describes the field
+	Description string `hcl:"description,optional" json:"description,omitempty"`
+
+	// Required fields must be set in the configuration, at least one block must
+	// be defined for required fields with the type list(block)
+	Required bool `hcl:"required,optional" json:"required,omitempty"`
+
+	// Computed attributes can not be set in the configuration, their value is
+	// set when the resource is processed i.e. by a parser callback
+	Computed bool `hcl:"computed,optional" json:"computed,omitempty"`
+
+	// Sensitive attributes are redacted when the resource is serialized
+	Sensitive bool `hcl:"sensitive,optional" json:"sensitive,omitempty"`
+
+	Fields []*SchemaField `hcl:"field,block" json:"fields,omitempty"`
+}
+
+// IsBlock returns true when the field is a nested block
+func (f *SchemaField) IsBlock() bool {
+	return f.Type == "block" || f.Type == "list(block)"
+}
+
+var schemaFieldRegex = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// reservedSchemaFields are the attributes and blocks of a resource that are
+// defined by the parser
+var reservedSchemaFields = []string{"depends_on", "disabled", "meta", "count", "for_each", "lifecycle"}
+
+// SchemaResource is a resource whose type has been defined by a Schema, the
+// values of the resource are stored in a struct that is created from the
+// schema at runtime
+type SchemaResource struct {
+	schema *Schema
+	typ    reflect.Type
+	value  reflect.Value
+}
+
+// NewSchemaResource creates a resource from the schema, an error is returned
+// when the schema is not valid
+func NewSchemaResource(s *Schema) (*SchemaResource, error) {
+	typ, err := schemaStruct(s.Fields, true)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid schema "%s": %s`, s.Type, err)
+	}
+
+	return &SchemaResource{schema: s, typ: typ, value: reflect.New(typ)}, nil
+}
+
+// NewResource returns a new empty resource with the same schema
+func (s *SchemaResource) NewResource() types.Resource {
+	return &SchemaResource{schema: s.schema, typ: s.typ, value: reflect.New(s.typ)}
+}
+
+// Schema returns the schema that defines the resource
+func (s *SchemaResource) Schema() *Schema {
+	return s.schema
+}
+
+// Value returns a pointer to the struct that contains the values of the
+// resource, the struct has the same hcl and json tags as a resource defined
+// by a Go struct
+func (s *SchemaResource) Value() any {
+	return s.value.Interface()
+}
+
+// Get returns the value of the top level attribute or block with the given name
+func (s *SchemaResource) Get(name string) (any, bool) {
+	f, ok := s.field(name)
+	if !ok {
+		return nil, false
+	}
+
+	return f.Interface(), true
+}
+
+// Set sets the value of the top level attribute or block with the given name,
+// numbers are converted to the type of the attribute
+func (s *SchemaResource) Set(name string, value any) error {
+	f, ok := s.field(name)
+	if !ok {
+		return fmt.Errorf(`resource "%s" does not have the attribute "%s"`, s.schema.Type, name)
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		f.Set(reflect.Zero(f.Type()))
+	case v.Type().AssignableTo(f.Type()):
+		f.Set(v)
+	case v.CanInt() && f.CanInt(), v.CanFloat() && f.CanInt():
+		f.Set(v.Convert(f.Type()))
+	default:
+		return fmt.Errorf(`unable to set attribute "%s", expected %s got %s`, name, f.Type(), v.Type())
+	}
+
+	return nil
+}
+
+// field returns the field of the struct with the given hcl name
+func (s *SchemaResource) field(name string) (reflect.Value, bool) {
+	// the first field is the resource base
+	for i := 1; i < s.typ.NumField(); i++ {
+		if strings.Split(s.typ.Field(i).Tag.Get("hcl"), ",")[0] == name {
+			return s.value.Elem().Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// base returns the resource base that is embedded in the struct
+func (s *SchemaResource) base() *types.ResourceBase {
+	return s.value.Elem().Field(0).Addr().Interface().(*types.ResourceBase)
+}
+
+func (s *SchemaResource) Metadata() *types.Meta {
+	return s.base().Metadata()
+}
+
+func (s *SchemaResource) GetDisabled() bool {
+	return s.base().GetDisabled()
+}
+
+func (s *SchemaResource) SetDisabled(v bool) {
+	s.base().SetDisabled(v)
+}
+
+func (s *SchemaResource) GetDependencies() []string {
+	return s.base().GetDependencies()
+}
+
+func (s *SchemaResource) SetDependencies(v []string) {
+	s.base().SetDependencies(v)
+}
+
+func (s *SchemaResource) AddDependency(v string) {
+	s.base().AddDependency(v)
+}
+
+// MarshalJSON serializes the values of the resource in the same format as a
+// resource defined by a Go struct
+func (s *SchemaResource) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.value.Interface())
+}
+
+// UnmarshalJSON sets the values of the resource from JSON created by MarshalJSON
+func (s *SchemaResource) UnmarshalJSON(d []byte) error {
+	if !s.value.IsValid() {
+		return fmt.Errorf("resource has not been created from a schema")
+	}
+
+	return json.Unmarshal(d, s.value.Interface())
+}
+
+// schemaStruct creates a struct type from the fields, the struct for a resource
+// embeds types.ResourceBase as the first field
+func schemaStruct(fields []*SchemaField, resource bool) (reflect.Type, error) {
+	sfs := []reflect.StructField{}
+	if resource {
+		sfs = append(sfs, reflect.StructField{
+			Name:      "ResourceBase",
+			Type:      reflect.TypeOf(types.ResourceBase{}),
+			Tag:       `hcl:",remain"`,
+			Anonymous: true,
+		})
+	}
+
+	names := map[string]bool{}
+	for _, f := range fields {
+		if !schemaFieldRegex.MatchString(f.Name) {
+			return nil, fmt.Errorf(`invalid field name "%s", names can only contain the characters a-z 0-9 _ and must start with a letter`, f.Name)
+		}
+
+		if resource && slices.Contains(reservedSchemaFields, f.Name) {
+			return nil, fmt.Errorf(`invalid field name "%s", the name is reserved`, f.Name)
+		}
+
+		goName := fieldName(f.Name)
+		if names[goName] {
+			return nil, fmt.Errorf(`field "%s" has been defined more than once`, f.Name)
+		}
+
+		names[goName] = true
+
+		sf, err := schemaStructField(f, goName)
+		if err != nil {
+			return nil, fmt.Errorf(`field "%s": %s`, f.Name, err)
+		}
+
+		sfs = append(sfs, sf)
+	}
+
+	return reflect.StructOf(sfs), nil
+}
+
+// schemaStructField creates the struct field for a field of the schema
+func schemaStructField(f *SchemaField, goName string) (reflect.StructField, error) {
+	if f.Required && f.Computed {
+		return reflect.StructField{}, fmt.Errorf("a field can not be both required and computed")
+	}
+
+	hclTag := f.Name
+	jsonTag := f.Name
+	if !f.Required {
+		jsonTag += ",omitempty"
+	}
+
+	var typ reflect.Type
+	if f.IsBlock() {
+		if f.Computed {
+			return reflect.StructField{}, fmt.Errorf("blocks can not be computed")
+		}
+
+		if len(f.Fields) == 0 {
+			return reflect.StructField{}, fmt.Errorf("blocks must define at least one field")
+		}
+
+		st, err := schemaStruct(f.Fields, false)
+		if err != nil {
+			return reflect.StructField{}, err
+		}
+
+		hclTag += ",block"
+
+		switch {
+		case f.Type == "list(block)":
+			typ = reflect.SliceOf(st)
+		case f.Required:
+			typ = st
+		default:
+			typ = reflect.PointerTo(st)
+		}
+	} else {
+		if len(f.Fields) > 0 {
+			return reflect.StructField{}, fmt.Errorf("only blocks can define fields")
+		}
+
+		at, err := attributeType(f.Type)
+		if err != nil {
+			return reflect.StructField{}, err
+		}
+
+		if !f.Required {
+			hclTag += ",optional"
+		}
+
+		typ = at
+	}
+
+	tag := fmt.Sprintf(`hcl:"%s" json:"%s"`, hclTag, jsonTag)
+	if f.Sensitive {
+		tag += ` sensitive:"true"`
+	}
+
+	if f.Computed {
+		tag += ` computed:"true"`
+	}
+
+	if f.Description != "" {
+		tag += fmt.Sprintf(` description:%s`, strconv.Quote(f.Description))
+	}
+
+	return reflect.StructField{Name: goName, Type: typ, Tag: reflect.StructTag(tag)}, nil
+}
+
+// attributeType returns the Go type for the type of an attribute
+func attributeType(t string) (reflect.Type, error) {
+	switch t {
+	case "string":
+		return reflect.TypeOf(""), nil
+	case "int":
+		return reflect.TypeOf(0), nil
+	case "bool":
+		return reflect.TypeOf(false), nil
+	}
+
+	if et, ok := strings.CutPrefix(t, "list("); ok && strings.HasSuffix(et, ")") {
+		e, err := attributeType(strings.TrimSuffix(et, ")"))
+		if err != nil {
+			return nil, err
+		}
+
+		return reflect.SliceOf(e), nil
+	}
+
+	if et, ok := strings.CutPrefix(t, "map("); ok && strings.HasSuffix(et, ")") {
+		e, err := attributeType(strings.TrimSuffix(et, ")"))
+		if err != nil {
+			return nil, err
+		}
+
+		return reflect.MapOf(reflect.TypeOf(""), e), nil
+	}
+
+	return nil, fmt.Errorf(`unknown type "%s", valid types are string, int, bool, list(type), map(type), block and list(block)`, t)
+}
+
+// fieldName converts the name of a field to an exported Go identifier i.e.
+// host_path becomes Host_Path
+func fieldName(name string) string {
+	parts := strings.Split(name, "_")
+	for i, p := range parts {
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+
+	return strings.Join(parts, "_")
+}
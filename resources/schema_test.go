@@ -0,0 +1,73 @@
+package resources
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSchema() *Schema {
+	return &Schema{
+		Type: "person",
+		Fields: []*SchemaField{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "age", Type: "int"},
+			{Name: "scores", Type: "map(list(int))"},
+		},
+	}
+}
+
+func TestNewResourceCreatesEmptyResource(t *testing.T) {
+	sr, err := NewSchemaResource(testSchema())
+	require.NoError(t, err)
+
+	err = sr.Set("name", "nic")
+	require.NoError(t, err)
+
+	nr := sr.NewResource().(*SchemaResource)
+
+	name, ok := nr.Get("name")
+	require.True(t, ok)
+	require.Equal(t, "", name)
+	require.Equal(t, sr.Schema(), nr.Schema())
+}
+
+func TestSetConvertsNumbers(t *testing.T) {
+	sr, err := NewSchemaResource(testSchema())
+	require.NoError(t, err)
+
+	err = sr.Set("age", float64(42))
+	require.NoError(t, err)
+
+	age, _ := sr.Get("age")
+	require.Equal(t, 42, age)
+
+	err = sr.Set("age", "42")
+	require.ErrorContains(t, err, `unable to set attribute "age"`)
+
+	err = sr.Set("height", 180)
+	require.ErrorContains(t, err, `does not have the attribute "height"`)
+}
+
+func TestSchemaResourceMarshalsJSON(t *testing.T) {
+	sr, err := NewSchemaResource(testSchema())
+	require.NoError(t, err)
+
+	sr.Metadata().Name = "nic"
+	sr.Set("name", "nic")
+	sr.Set("scores", map[string][]int{"maths": {1, 2}})
+
+	d, err := json.Marshal(sr)
+	require.NoError(t, err)
+	require.Contains(t, string(d), `"name":"nic"`)
+	require.Contains(t, string(d), `"scores":{"maths":[1,2]}`)
+
+	nr := sr.NewResource()
+	err = json.Unmarshal(d, nr)
+	require.NoError(t, err)
+	require.Equal(t, "nic", nr.Metadata().Name)
+
+	scores, _ := nr.(*SchemaResource).Get("scores")
+	require.Equal(t, map[string][]int{"maths": {1, 2}}, scores)
+}
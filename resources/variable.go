@@ -1,12 +1,67 @@
 package resources
 
-import "github.com/jumppad-labs/hclconfig/types"
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/jumppad-labs/hclconfig/types"
+)
 
 const TypeVariable = "variable"
 
+const (
+	// VariableSourceDefault is the source of a value set from the default of the variable
+	VariableSourceDefault = "default"
+	// VariableSourceFile is the source of a value set from a variables file
+	VariableSourceFile = "file"
+	// VariableSourceEnv is the source of a value set from an environment variable
+	VariableSourceEnv = "env"
+	// VariableSourceOption is the source of a value set from ParserOptions.Variables
+	VariableSourceOption = "option"
+	// VariableSourceInput is the source of a value set from the variables of a module block
+	VariableSourceInput = "input"
+)
+
 // Output defines an output variable which can be set by a module
 type Variable struct {
 	types.ResourceBase `hcl:",remain"`
-	Default            any    `hcl:"default" json:"default"`                            // default value for a variable
-	Description        string `hcl:"description,optional" json:"description,omitempty"` // description of the variable
+	Default            any    `hcl:"default,optional" json:"default" description:"default value of the variable, variables without a default must be set"`
+	Description        string `hcl:"description,optional" json:"description,omitempty" description:"description of the variable"`
+	Sensitive          bool   `hcl:"sensitive,optional" json:"sensitive,omitempty" description:"the value of the variable is redacted"`
+
+	// Type is the type constraint for the variable i.e. list(string) or
+	// object({name = string, port = optional(number, 80)}), when set the value of the
+	// variable is converted to this type
+	Type hcl.Expression `hcl:"type,optional" json:"-" description:"type constraint for the variable i.e. list(string)"`
+
+	// Validations are the rules that the final value of the variable must satisfy
+	Validations []Validation `hcl:"validation,block" json:"-" description:"rules that the value of the variable must satisfy"`
+
+	// Source is where the final value of the variable was set, it is populated
+	// by the parser
+	Source *VariableSource `json:"source,omitempty"`
+}
+
+// VariableSource describes where the value of a variable was set
+type VariableSource struct {
+	// Type of the source i.e. default, file, env, option or input
+	Type string `json:"type"`
+	// Name of the source, the path of the variables file, the name of the
+	// environment variable or the module that set the input
+	Name string `json:"name,omitempty"`
+}
+
+// String returns the source formatted as type or type:name
+func (s *VariableSource) String() string {
+	if s.Name == "" {
+		return s.Type
+	}
+
+	return s.Type + ":" + s.Name
+}
+
+// Validation defines a rule for the value of a variable, the condition is evaluated
+// once the value of the variable has been resolved and the error message is
+// returned when the condition is false
+type Validation struct {
+	Condition    hcl.Expression `hcl:"condition" json:"-" description:"expression that must be true for the value to be valid"`
+	ErrorMessage hcl.Expression `hcl:"error_message" json:"-" description:"message returned when the condition is false"`
 }
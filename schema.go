@@ -0,0 +1,147 @@
+package hclconfig
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+)
+
+// schemaFile is the definition of a file containing schema blocks
+type schemaFile struct {
+	Schemas []*resources.Schema `hcl:"schema,block"`
+}
+
+// RegisterSchema registers the resource types defined by the schema blocks in
+// the given file i.e. schema "person" {}, files with the extension .json are
+// parsed using the HCL JSON syntax. Resources defined by a schema are created
+// as a *resources.SchemaResource and can be used in the same way as resources
+// defined by a Go struct.
+func (p *Parser) RegisterSchema(filename string, src []byte) error {
+	parser := hclparse.NewParser()
+
+	var f *hcl.File
+	var diags hcl.Diagnostics
+	if filepath.Ext(filename) == ".json" {
+		f, diags = parser.ParseJSON(src, filename)
+	} else {
+		f, diags = parser.ParseHCL(src, filename)
+	}
+
+	if diags.HasErrors() {
+		return schemaError(diags)
+	}
+
+	sf := &schemaFile{}
+	if diags := gohcl.DecodeBody(f.Body, nil, sf); diags.HasErrors() {
+		return schemaError(diags)
+	}
+
+	// validate all the schemas before registering any of them
+	srs := []*resources.SchemaResource{}
+	defined := map[string]bool{}
+	for _, s := range sf.Schemas {
+		if _, ok := p.registeredTypes[s.Type]; ok {
+			return fmt.Errorf(`unable to register schema "%s", a resource with the same type has already been registered`, s.Type)
+		}
+
+		if defined[s.Type] {
+			return fmt.Errorf(`unable to register schema "%s", the type has been defined more than once`, s.Type)
+		}
+
+		defined[s.Type] = true
+
+		sr, err := resources.NewSchemaResource(s)
+		if err != nil {
+			return err
+		}
+
+		srs = append(srs, sr)
+	}
+
+	for _, sr := range srs {
+		p.RegisterType(sr.Schema().Type, sr)
+	}
+
+	return nil
+}
+
+// schemaError creates a ParserError from the first error in the diagnostics
+func schemaError(diags hcl.Diagnostics) *errors.ParserError {
+	de := &errors.ParserError{}
+	if diags[0].Subject != nil {
+		de.Filename = diags[0].Subject.Filename
+		de.Line = diags[0].Subject.Start.Line
+		de.Column = diags[0].Subject.Start.Column
+	}
+
+	de.Level = errors.ParserErrorLevelError
+	de.Message = fmt.Sprintf("unable to parse schema: %s", diags[0].Detail)
+
+	return de
+}
+
+// resourceValue returns the value that the body of the resource is decoded into,
+// resources defined by a schema store their values in a struct created at
+// runtime
+func resourceValue(r types.Resource) any {
+	if sr, ok := r.(*resources.SchemaResource); ok {
+		return sr.Value()
+	}
+
+	return r
+}
+
+// validateSchemaBody checks that the body of a resource defined by a schema does
+// not set any computed attributes and defines the required blocks
+func validateSchemaBody(r types.Resource, body hcl.Body) *errors.ParserError {
+	sr, ok := r.(*resources.SchemaResource)
+	if !ok {
+		return nil
+	}
+
+	return validateSchemaFields(r, sr.Schema().Fields, body)
+}
+
+func validateSchemaFields(r types.Resource, fields []*resources.SchemaField, body hcl.Body) *errors.ParserError {
+	schema := &hcl.BodySchema{}
+	for _, f := range fields {
+		switch {
+		case f.IsBlock():
+			schema.Blocks = append(schema.Blocks, hcl.BlockHeaderSchema{Type: f.Name})
+		case f.Computed:
+			schema.Attributes = append(schema.Attributes, hcl.AttributeSchema{Name: f.Name})
+		}
+	}
+
+	// any errors in the body are reported when it is decoded
+	content, _, _ := body.PartialContent(schema)
+
+	for _, f := range fields {
+		if attr, ok := content.Attributes[f.Name]; ok {
+			return createRangeError(attr.NameRange, fmt.Sprintf(`unable to set "%s" for resource "%s", the attribute is computed`, f.Name, r.Metadata().ID))
+		}
+
+		if !f.IsBlock() {
+			continue
+		}
+
+		blocks := content.Blocks.OfType(f.Name)
+		if f.Required && len(blocks) == 0 {
+			return createParserError(r, fmt.Sprintf(`at least one "%s" block is required for resource "%s"`, f.Name, r.Metadata().ID))
+		}
+
+		for _, b := range blocks {
+			if err := validateSchemaFields(r, f.Fields, b.Body); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
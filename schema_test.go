@@ -0,0 +1,315 @@
+package hclconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSchemaParser(t *testing.T, options ...*ParserOptions) *Parser {
+	p := setupParser(t, options...)
+
+	for _, f := range []string{"./test_fixtures/schema/person.hcl", "./test_fixtures/schema/team.json"} {
+		src, err := os.ReadFile(f)
+		require.NoError(t, err)
+
+		err = p.RegisterSchema(f, src)
+		require.NoError(t, err)
+	}
+
+	return p
+}
+
+func parseSchemaConfig(t *testing.T) (*Parser, *Config) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/schema/config")
+	require.NoError(t, err)
+
+	o := DefaultOptions()
+	o.Callback = func(r types.Resource) error {
+		// set the computed attribute
+		if sr, ok := r.(*resources.SchemaResource); ok && r.Metadata().Type == "person" {
+			name, _ := sr.Get("name")
+			return sr.Set("id", "person-"+name.(string))
+		}
+
+		return nil
+	}
+
+	p := setupSchemaParser(t, o)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	return p, c
+}
+
+func TestRegisterSchemaCreatesResources(t *testing.T) {
+	_, c := parseSchemaConfig(t)
+
+	r, err := c.FindResource("resource.person.nic")
+	require.NoError(t, err)
+	require.IsType(t, &resources.SchemaResource{}, r)
+
+	nic := r.(*resources.SchemaResource)
+
+	name, _ := nic.Get("name")
+	require.Equal(t, "Nic", name)
+
+	age, _ := nic.Get("age")
+	require.Equal(t, 42, age)
+
+	admin, _ := nic.Get("admin")
+	require.Equal(t, true, admin)
+
+	tags, _ := nic.Get("tags")
+	require.Equal(t, []string{"dev", "ops"}, tags)
+
+	labels, _ := nic.Get("labels")
+	require.Equal(t, map[string]string{"team": "platform"}, labels)
+
+	id, _ := nic.Get("id")
+	require.Equal(t, "person-Nic", id)
+
+	r, err = c.FindResource("resource.person.erik")
+	require.NoError(t, err)
+
+	age, _ = r.(*resources.SchemaResource).Get("age")
+	require.Equal(t, 43, age)
+}
+
+func TestSchemaResourcesCanBeReferenced(t *testing.T) {
+	_, c := parseSchemaConfig(t)
+
+	r, err := c.FindResource("resource.team.platform")
+	require.NoError(t, err)
+
+	team := r.(*resources.SchemaResource)
+
+	name, _ := team.Get("name")
+	require.Equal(t, "platform", name)
+
+	members, _ := team.Get("members")
+	require.Equal(t, []string{"Nic", "Erik"}, members)
+
+	// references to nested blocks
+	r, err = c.FindResource("output.first_pet")
+	require.NoError(t, err)
+	require.Equal(t, "Rocky", r.(*resources.Output).Value)
+
+	// references to computed attributes set by the callback
+	r, err = c.FindResource("output.nic_id")
+	require.NoError(t, err)
+	require.Equal(t, "person-Nic", r.(*resources.Output).Value)
+
+	// the dependencies are resolved from the references
+	r, err = c.FindResource("resource.person.erik")
+	require.NoError(t, err)
+	require.Contains(t, r.Metadata().Links, "resource.person.nic.age")
+}
+
+func TestSchemaResourcesSerializeToJSON(t *testing.T) {
+	p, c := parseSchemaConfig(t)
+
+	d, err := c.ToJSON()
+	require.NoError(t, err)
+	require.Contains(t, string(d), `"password": "(sensitive value)"`)
+	require.Contains(t, string(d), `"city": "London"`)
+
+	d, err = c.ToJSONUnredacted()
+	require.NoError(t, err)
+
+	nc, err := p.UnmarshalJSON(d)
+	require.NoError(t, err)
+
+	r, err := nc.FindResource("resource.person.nic")
+	require.NoError(t, err)
+	require.IsType(t, &resources.SchemaResource{}, r)
+
+	orig, _ := c.FindResource("resource.person.nic")
+	require.Equal(t, orig.Metadata().Checksum, r.Metadata().Checksum)
+
+	for _, attr := range []string{"name", "password", "address", "pet", "id"} {
+		ov, _ := orig.(*resources.SchemaResource).Get(attr)
+		nv, _ := r.(*resources.SchemaResource).Get(attr)
+		require.Equal(t, ov, nv)
+	}
+
+	diff, err := c.Diff(nc)
+	require.NoError(t, err)
+	require.Empty(t, diff.ParseUpdated)
+	require.Empty(t, diff.ProcessedUpdated)
+}
+
+func TestSchemaComputedAttributesCanNotBeSet(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/schema/invalid/computed.hcl")
+	require.NoError(t, err)
+
+	p := setupSchemaParser(t)
+
+	_, err = p.ParseFile(absoluteFolderPath)
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, `unable to set "id" for resource "resource.person.nic", the attribute is computed`, pe.Message)
+	require.Equal(t, 3, pe.Line)
+}
+
+func TestSchemaRequiredBlocksMustBeDefined(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/schema/invalid/required.hcl")
+	require.NoError(t, err)
+
+	p := setupSchemaParser(t)
+
+	_, err = p.ParseFile(absoluteFolderPath)
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Equal(t, `at least one "lead" block is required for resource "resource.team.platform"`, pe.Message)
+}
+
+func TestRegisterSchemaReturnsErrorForInvalidSchema(t *testing.T) {
+	tt := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{
+			"unknown type",
+			`schema "thing" {
+  field "size" {
+    type = "float"
+  }
+}`,
+			`invalid schema "thing": field "size": unknown type "float"`,
+		},
+		{
+			"unknown element type",
+			`schema "thing" {
+  field "sizes" {
+    type = "list(float)"
+  }
+}`,
+			`invalid schema "thing": field "sizes": unknown type "float"`,
+		},
+		{
+			"reserved name",
+			`schema "thing" {
+  field "depends_on" {
+    type = "string"
+  }
+}`,
+			`invalid schema "thing": invalid field name "depends_on", the name is reserved`,
+		},
+		{
+			"invalid name",
+			`schema "thing" {
+  field "Size" {
+    type = "string"
+  }
+}`,
+			`invalid schema "thing": invalid field name "Size"`,
+		},
+		{
+			"duplicate name",
+			`schema "thing" {
+  field "size" {
+    type = "string"
+  }
+
+  field "size" {
+    type = "int"
+  }
+}`,
+			`invalid schema "thing": field "size" has been defined more than once`,
+		},
+		{
+			"required and computed",
+			`schema "thing" {
+  field "id" {
+    type     = "string"
+    required = true
+    computed = true
+  }
+}`,
+			`invalid schema "thing": field "id": a field can not be both required and computed`,
+		},
+		{
+			"block without fields",
+			`schema "thing" {
+  field "part" {
+    type = "block"
+  }
+}`,
+			`invalid schema "thing": field "part": blocks must define at least one field`,
+		},
+		{
+			"attribute with fields",
+			`schema "thing" {
+  field "part" {
+    type = "string"
+
+    field "name" {
+      type = "string"
+    }
+  }
+}`,
+			`invalid schema "thing": field "part": only blocks can define fields`,
+		},
+		{
+			"registered type",
+			`schema "container" {
+  field "name" {
+    type = "string"
+  }
+}`,
+			`unable to register schema "container", a resource with the same type has already been registered`,
+		},
+		{
+			"duplicate type",
+			`schema "thing" {
+  field "name" {
+    type = "string"
+  }
+}
+
+schema "thing" {
+  field "size" {
+    type = "int"
+  }
+}`,
+			`unable to register schema "thing", the type has been defined more than once`,
+		},
+		{
+			"missing type",
+			`schema "thing" {
+  field "name" {}
+}`,
+			`unable to parse schema: The argument "type" is required`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := setupParser(t)
+
+			err := p.RegisterSchema("schema.hcl", []byte(tc.schema))
+			require.Error(t, err)
+
+			if pe, ok := err.(*errors.ParserError); ok {
+				require.Contains(t, pe.Message, tc.err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.err)
+
+			// types are not registered when the schema is invalid
+			_, err = p.registeredTypes.CreateResource("thing", "test")
+			require.Error(t, err)
+		})
+	}
+}
@@ -1,4 +1,9 @@
-person {
+resource "person" "nic" {
   name = "nic"
   age  = 99
-}
\ No newline at end of file
+
+  pets {
+    name = "rocky"
+    age  = 5
+  }
+}
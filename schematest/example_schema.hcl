@@ -8,7 +8,7 @@ schema "person" {
   }
 
   field "pets" {
-    type = "block"
+    type = "list(block)"
 
     field "name" {
       type = "string"
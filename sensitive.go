@@ -0,0 +1,469 @@
+package hclconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// valueMark is the type for marks applied to cty values
+type valueMark string
+
+// markSensitive is applied to the values of sensitive variables and attributes,
+// cty keeps the mark on any value derived from a sensitive value so sensitivity
+// follows the value through references
+const markSensitive = valueMark("sensitive")
+
+// unmarkBody wraps a hcl.Body and removes the marks from the values of its
+// attributes so that they can be decoded into Go types, onSensitive is called
+// with the path of any attribute that contains a sensitive value
+type unmarkBody struct {
+	body        hcl.Body
+	path        string
+	onSensitive func(path string, val cty.Value)
+}
+
+func newUnmarkBody(b hcl.Body, onSensitive func(path string, val cty.Value)) hcl.Body {
+	return &unmarkBody{body: b, onSensitive: onSensitive}
+}
+
+func (b *unmarkBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, diags := b.body.Content(schema)
+	return b.content(content), diags
+}
+
+func (b *unmarkBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content, remain, diags := b.body.PartialContent(schema)
+	return b.content(content), &unmarkBody{body: remain, path: b.path, onSensitive: b.onSensitive}, diags
+}
+
+func (b *unmarkBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.body.JustAttributes()
+	return b.attributes(attrs), diags
+}
+
+func (b *unmarkBody) MissingItemRange() hcl.Range {
+	return b.body.MissingItemRange()
+}
+
+func (b *unmarkBody) content(content *hcl.BodyContent) *hcl.BodyContent {
+	if content == nil {
+		return nil
+	}
+
+	c := *content
+	c.Attributes = b.attributes(content.Attributes)
+	c.Blocks = hcl.Blocks{}
+
+	// blocks are addressed by their type and position i.e. network[1]
+	index := map[string]int{}
+	for _, block := range content.Blocks {
+		nb := *block
+		nb.Body = &unmarkBody{
+			body:        block.Body,
+			path:        fmt.Sprintf("%s[%d]", joinAttributePath(b.path, block.Type), index[block.Type]),
+			onSensitive: b.onSensitive,
+		}
+
+		index[block.Type]++
+		c.Blocks = append(c.Blocks, &nb)
+	}
+
+	return &c
+}
+
+func (b *unmarkBody) attributes(attrs hcl.Attributes) hcl.Attributes {
+	if attrs == nil {
+		return nil
+	}
+
+	unmarked := hcl.Attributes{}
+	for name, attr := range attrs {
+		a := *attr
+		a.Expr = &unmarkExpr{Expression: attr.Expr, path: joinAttributePath(b.path, name), onSensitive: b.onSensitive}
+		unmarked[name] = &a
+	}
+
+	return unmarked
+}
+
+// unmarkExpr wraps a hcl.Expression and removes the marks from its value
+type unmarkExpr struct {
+	hcl.Expression
+	path        string
+	onSensitive func(path string, val cty.Value)
+}
+
+func (e *unmarkExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	marked, diags := e.Expression.Value(ctx)
+
+	val, pvm := marked.UnmarkDeepWithPaths()
+	if e.onSensitive != nil && containsMark(pvm, markSensitive) {
+		e.onSensitive(e.path, marked)
+	}
+
+	return val, diags
+}
+
+// unwrapMarked returns the original expression for an expression that has been
+// wrapped by unmarkBody
+func unwrapMarked(expr hcl.Expression) hcl.Expression {
+	if e, ok := expr.(*unmarkExpr); ok {
+		return e.Expression
+	}
+
+	return expr
+}
+
+func containsMark(pvm []cty.PathValueMarks, mark valueMark) bool {
+	for _, pm := range pvm {
+		if _, ok := pm.Marks[mark]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinAttributePath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+// sensitivePaths returns the paths of the attributes of the resource that contain
+// sensitive values, these are the attributes that have been set from a sensitive
+// value, fields tagged with `sensitive:"true"` and the value of sensitive outputs
+// and variables
+func sensitivePaths(r types.Resource) []string {
+	paths := slices.Clone(r.Metadata().Sensitive)
+	paths = append(paths, taggedSensitivePaths(reflect.ValueOf(resourceValue(r)), "")...)
+
+	switch v := r.(type) {
+	case *resources.Output:
+		if v.Sensitive {
+			paths = append(paths, "value")
+		}
+	case *resources.Variable:
+		if v.Sensitive {
+			paths = append(paths, "default")
+		}
+	}
+
+	sort.Strings(paths)
+
+	return slices.Compact(paths)
+}
+
+// taggedSensitivePaths returns the paths of the fields that are tagged as sensitive
+// i.e. Password string `hcl:"password" sensitive:"true"`, nested blocks are
+// addressed by their position i.e. user[0].password
+func taggedSensitivePaths(v reflect.Value, path string) []string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	paths := []string{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := strings.Split(field.Tag.Get("hcl"), ",")[0]
+
+		// the embedded resource base contains the meta data for the resource
+		if field.Type == reflect.TypeOf(types.ResourceBase{}) {
+			continue
+		}
+
+		if name == "" {
+			if field.Anonymous {
+				paths = append(paths, taggedSensitivePaths(v.Field(i), path)...)
+			}
+
+			continue
+		}
+
+		fieldPath := joinAttributePath(path, name)
+		if field.Tag.Get("sensitive") == "true" {
+			paths = append(paths, fieldPath)
+			continue
+		}
+
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for j := 0; j < fv.Len(); j++ {
+				paths = append(paths, taggedSensitivePaths(fv.Index(j), fmt.Sprintf("%s[%d]", fieldPath, j))...)
+			}
+		default:
+			paths = append(paths, taggedSensitivePaths(fv, fieldPath)...)
+		}
+	}
+
+	return paths
+}
+
+// markSensitivePaths marks the values at the given paths as sensitive
+func markSensitivePaths(val cty.Value, paths []string) cty.Value {
+	for _, p := range paths {
+		val = markPath(val, parseAttributePath(p))
+	}
+
+	return val
+}
+
+// pathStep is an element of an attribute path, either the name of an attribute
+// or the index of a block
+type pathStep struct {
+	name  string
+	index *int
+}
+
+// parseAttributePath parses a path like network[0].name into its steps
+func parseAttributePath(path string) []pathStep {
+	steps := []pathStep{}
+
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		steps = append(steps, pathStep{name: name})
+
+		for rest != "" {
+			var idx string
+			idx, rest, _ = strings.Cut(rest, "]")
+			rest = strings.TrimPrefix(rest, "[")
+
+			if i, err := strconv.Atoi(idx); err == nil {
+				steps = append(steps, pathStep{index: &i})
+			}
+		}
+	}
+
+	return steps
+}
+
+// markPath marks the value at the given path, paths that do not exist are ignored
+// and when a path can not be followed the closest parent is marked so that the
+// sensitive value is never exposed
+func markPath(val cty.Value, path []pathStep) cty.Value {
+	if len(path) == 0 || val.IsNull() || !val.IsKnown() || val.IsMarked() {
+		return val.Mark(markSensitive)
+	}
+
+	step := path[0]
+	ty := val.Type()
+
+	switch {
+	case step.index == nil && (ty.IsObjectType() || ty.IsMapType()):
+		attrs := val.AsValueMap()
+		if _, ok := attrs[step.name]; !ok {
+			return val
+		}
+
+		attrs[step.name] = markPath(attrs[step.name], path[1:])
+		if ty.IsMapType() {
+			return cty.MapVal(attrs)
+		}
+
+		return cty.ObjectVal(attrs)
+	case step.index != nil && (ty.IsListType() || ty.IsTupleType()):
+		elems := val.AsValueSlice()
+		if *step.index >= len(elems) {
+			return val
+		}
+
+		elems[*step.index] = markPath(elems[*step.index], path[1:])
+		if ty.IsListType() {
+			return cty.ListVal(elems)
+		}
+
+		return cty.TupleVal(elems)
+	case step.index != nil && ty.IsObjectType():
+		// single blocks are decoded as an object rather than a list
+		return markPath(val, path[1:])
+	}
+
+	return val.Mark(markSensitive)
+}
+
+// sensitiveStrings returns the strings in the value that have been marked as
+// sensitive, or that are contained in a collection marked as sensitive
+func sensitiveStrings(val cty.Value) []string {
+	val, marks := val.Unmark()
+	if _, ok := marks[markSensitive]; ok {
+		return allStrings(val)
+	}
+
+	if val.IsNull() || !val.IsKnown() || !val.Type().IsCollectionType() && !val.Type().IsObjectType() && !val.Type().IsTupleType() {
+		return nil
+	}
+
+	values := []string{}
+	for it := val.ElementIterator(); it.Next(); {
+		_, v := it.Element()
+		values = append(values, sensitiveStrings(v)...)
+	}
+
+	return values
+}
+
+// allStrings returns every string contained in the value
+func allStrings(val cty.Value) []string {
+	val, _ = val.UnmarkDeep()
+
+	if val.IsNull() || !val.IsKnown() {
+		return nil
+	}
+
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
+		return []string{val.AsString()}
+	case ty.IsCollectionType() || ty.IsObjectType() || ty.IsTupleType():
+		values := []string{}
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			values = append(values, allStrings(v)...)
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+// redactJSON replaces the values at the sensitive paths of the resource in its
+// serialized form with types.RedactedValue, paths use the hcl names of the
+// attributes which are mapped to the json names of the fields
+func redactJSON(r types.Resource, data map[string]any) {
+	for _, p := range sensitivePaths(r) {
+		// the value of outputs and locals is serialized from the Value field
+		switch r.(type) {
+		case *resources.Output, *resources.Local:
+			if p == "value" {
+				data["value"] = types.RedactedValue
+				continue
+			}
+		}
+
+		redactJSONPath(reflect.TypeOf(resourceValue(r)), data, parseAttributePath(p))
+	}
+}
+
+func redactJSONPath(t reflect.Type, data any, path []pathStep) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if len(path) == 0 {
+		return types.RedactedValue
+	}
+
+	step := path[0]
+
+	switch d := data.(type) {
+	case map[string]any:
+		if step.index != nil || t.Kind() != reflect.Struct {
+			return data
+		}
+
+		field, ok := hclField(t, step.name)
+		if !ok {
+			return data
+		}
+
+		key := jsonName(field)
+		if _, ok := d[key]; !ok || key == "-" {
+			return data
+		}
+
+		ft := field.Type
+		// the index of a single block can be ignored
+		rest := path[1:]
+		if len(rest) > 0 && rest[0].index != nil && ft.Kind() != reflect.Slice && ft.Kind() != reflect.Array {
+			rest = rest[1:]
+		}
+
+		d[key] = redactJSONPath(ft, d[key], rest)
+	case []any:
+		if step.index == nil || *step.index >= len(d) || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
+			return data
+		}
+
+		d[*step.index] = redactJSONPath(t.Elem(), d[*step.index], path[1:])
+	}
+
+	return data
+}
+
+// hclField returns the struct field with the given hcl name, fields of embedded
+// structs are included
+func hclField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if strings.Split(f.Tag.Get("hcl"), ",")[0] == name {
+			return f, true
+		}
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if ef, ok := hclField(f.Type, name); ok {
+				return ef, true
+			}
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// jsonName returns the key used by encoding/json for the field
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+
+	return name
+}
+
+// redactedResources serializes the resources replacing any sensitive values
+func redactedResources(rs []types.Resource) ([]any, error) {
+	redacted := []any{}
+
+	for _, r := range rs {
+		if len(sensitivePaths(r)) == 0 {
+			redacted = append(redacted, r)
+			continue
+		}
+
+		d, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+
+		data := map[string]any{}
+		if err := json.Unmarshal(d, &data); err != nil {
+			return nil, err
+		}
+
+		redactJSON(r, data)
+		redacted = append(redacted, data)
+	}
+
+	return redacted, nil
+}
@@ -0,0 +1,176 @@
+package hclconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/test_fixtures/structs"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSensitiveFixture(t *testing.T, options ...*ParserOptions) *Config {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/sensitive")
+	require.NoError(t, err)
+
+	p := setupParser(t, options...)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestSensitiveValuesFollowReferences(t *testing.T) {
+	c := parseSensitiveFixture(t)
+
+	r, err := c.FindResource("resource.container.db")
+	require.NoError(t, err)
+	require.Equal(t, []string{"env"}, r.Metadata().Sensitive)
+
+	// the resource contains the real value
+	require.Equal(t, "s3cr3t-pa55", r.(*structs.Container).Env["PASSWORD"])
+
+	r, err = c.FindResource("resource.container.app")
+	require.NoError(t, err)
+	require.Equal(t, []string{"command"}, r.Metadata().Sensitive)
+	require.Equal(t, []string{"connect", "s3cr3t-pa55"}, r.(*structs.Container).Command)
+}
+
+func TestSensitiveOutputsContainTheRealValue(t *testing.T) {
+	c := parseSensitiveFixture(t)
+
+	outputs := map[string]string{
+		// derived from a sensitive variable
+		"output.connection": "admin:s3cr3t-pa55",
+		// derived from a field tagged as sensitive
+		"output.registry_password": "registry-pa55",
+		// marked as sensitive
+		"output.token": "not-a-real-token",
+	}
+
+	for k, v := range outputs {
+		r, err := c.FindResource(k)
+		require.NoError(t, err)
+
+		o := r.(*resources.Output)
+		require.True(t, o.Sensitive, k)
+		require.Equal(t, v, o.Value, k)
+	}
+
+	r, err := c.FindResource("output.username")
+	require.NoError(t, err)
+
+	o := r.(*resources.Output)
+	require.False(t, o.Sensitive)
+	require.Equal(t, "admin", o.Value)
+}
+
+func TestToJSONRedactsSensitiveValues(t *testing.T) {
+	c := parseSensitiveFixture(t)
+
+	d, err := c.ToJSON()
+	require.NoError(t, err)
+
+	require.NotContains(t, string(d), "s3cr3t-pa55")
+	require.NotContains(t, string(d), "registry-pa55")
+	require.NotContains(t, string(d), "not-a-real-token")
+	require.Contains(t, string(d), types.RedactedValue)
+	require.Contains(t, string(d), "admin")
+
+	d, err = c.ToJSONUnredacted()
+	require.NoError(t, err)
+
+	require.Contains(t, string(d), "s3cr3t-pa55")
+	require.Contains(t, string(d), "registry-pa55")
+	require.Contains(t, string(d), "not-a-real-token")
+}
+
+func TestUnmarshalJSONKeepsSensitiveOutputs(t *testing.T) {
+	c := parseSensitiveFixture(t)
+
+	d, err := c.ToJSONUnredacted()
+	require.NoError(t, err)
+
+	p := setupParser(t)
+
+	nc, err := p.UnmarshalJSON(d)
+	require.NoError(t, err)
+
+	r, err := nc.FindResource("output.token")
+	require.NoError(t, err)
+
+	o := r.(*resources.Output)
+	require.True(t, o.Sensitive)
+	require.Equal(t, "not-a-real-token", o.Value)
+
+	// the value is still redacted when the config is serialized again
+	d, err = nc.ToJSON()
+	require.NoError(t, err)
+	require.NotContains(t, string(d), "not-a-real-token")
+
+	r, err = nc.FindResource("output.username")
+	require.NoError(t, err)
+	require.Equal(t, "admin", r.(*resources.Output).Value)
+}
+
+func TestParserErrorsRedactSensitiveValues(t *testing.T) {
+	o := DefaultOptions()
+	o.Callback = func(r types.Resource) error {
+		if c, ok := r.(*structs.Container); ok && c.Meta.Name == "db" {
+			return fmt.Errorf("unable to connect with password %s", c.Env["PASSWORD"])
+		}
+
+		return nil
+	}
+
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/sensitive")
+	require.NoError(t, err)
+
+	p := setupParser(t, o)
+
+	_, err = p.ParseDirectory(absoluteFolderPath)
+	require.Error(t, err)
+
+	pe := err.(*errors.ConfigError).Errors[0].(*errors.ParserError)
+	require.Contains(t, pe.Message, "unable to connect with password (sensitive value)")
+	require.NotContains(t, err.Error(), "s3cr3t-pa55")
+}
+
+func TestVariableValidationRedactsSensitiveValues(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "password" {
+  default   = "short"
+  sensitive = true
+
+  validation {
+    condition     = len(variable.password) > 8
+    error_message = "password ${variable.password} is too short"
+  }
+}
+`))
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "password short")
+	require.ErrorContains(t, err, `invalid value for variable "password": (sensitive value)`)
+}
+
+func TestForEachReturnsErrorForSensitiveValues(t *testing.T) {
+	p := setupParser(t)
+
+	_, err := p.ParseSource("main.hcl", []byte(`
+variable "names" {
+  default   = ["alpha", "beta"]
+  sensitive = true
+}
+
+resource "container" "app" {
+  for_each = variable.names
+}
+`))
+	require.ErrorContains(t, err, "for_each can not contain sensitive values")
+}
@@ -0,0 +1,24 @@
+variable "docker_version" {
+  default = "24.0"
+}
+
+provider "docker" {
+  version = variable.docker_version
+}
+
+resource "container" "builder" {
+  command = ["docker", "--host", provider.docker.host]
+
+  env = {
+    DOCKER_VERSION = provider.docker.version
+  }
+}
+
+pipeline "build" "app" {
+  container = resource.container.builder.meta.name
+  steps     = ["test", "build"]
+}
+
+output "steps" {
+  value = pipeline.build.app.steps
+}
@@ -0,0 +1,42 @@
+variable "replicas" {
+  default = 3
+}
+
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" "app" {
+  resources {
+    cpu = 2000
+  }
+}
+
+local "max_cpu" {
+  value = 4000
+}
+
+output "subnet" {
+  value = resource.network.main.subnet
+}
+
+check "cpu" {
+  assert {
+    condition     = resource.container.app.resources.cpu <= local.max_cpu
+    error_message = "the container must not use more than ${local.max_cpu} cpu"
+  }
+}
+
+check "availability" {
+  severity = "warning"
+
+  assert {
+    condition     = output.subnet == "10.0.0.0/16"
+    error_message = "the network should use the subnet 10.0.0.0/16"
+  }
+
+  assert {
+    condition     = variable.replicas >= 2
+    error_message = "at least 2 replicas should be used for high availability, got ${variable.replicas}"
+  }
+}
@@ -0,0 +1,45 @@
+variable "workers" {
+  default = 3
+}
+
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" "worker" {
+  count = variable.workers
+
+  command = ["worker", "${count.index}"]
+
+  network {
+    name = resource.network.main.meta.name
+  }
+}
+
+resource "container" "none" {
+  count = 0
+}
+
+resource "container" "first" {
+  command = resource.container.worker[0].command
+}
+
+output "worker_ids" {
+  value = resource.container.worker[*].meta.id
+}
+
+output "worker_count" {
+  value = len(resource.container.worker)
+}
+
+output "none_count" {
+  value = len(resource.container.none)
+}
+
+module "workers" {
+  source = "./modules/worker"
+
+  variables = {
+    workers = 2
+  }
+}
@@ -0,0 +1,13 @@
+variable "workers" {
+  default = 1
+}
+
+resource "container" "worker" {
+  count = variable.workers
+
+  command = ["worker", "${count.index}"]
+}
+
+output "commands" {
+  value = resource.container.worker[*].command
+}
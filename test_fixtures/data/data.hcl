@@ -0,0 +1,17 @@
+data "network" "existing" {
+  name = "onprem"
+}
+
+resource "network" "existing" {
+  subnet = data.network.existing.subnet
+}
+
+resource "container" "app" {
+  network {
+    name = data.network.existing.name
+  }
+}
+
+output "subnet" {
+  value = data.network.existing.subnet
+}
@@ -0,0 +1,40 @@
+variable "ports" {
+  default = [
+    { local = 8080, remote = 80 },
+    { local = 8443, remote = 443 },
+  ]
+}
+
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "network" "backend" {
+  subnet = "10.1.0.0/16"
+}
+
+resource "container" "app" {
+  dynamic "network" {
+    for_each = [resource.network.main, resource.network.backend]
+
+    content {
+      name       = network.value.meta.name
+      ip_address = "10.${network.key}.0.200"
+    }
+  }
+
+  dynamic "port" {
+    for_each = variable.ports
+    iterator = p
+
+    content {
+      local  = p.value.local
+      remote = p.value.remote
+    }
+  }
+
+  volume {
+    source      = "./data"
+    destination = "/data"
+  }
+}
@@ -0,0 +1,56 @@
+variable "apps" {
+  default = {
+    api = "api:v1"
+    web = "web:v2"
+  }
+}
+
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" "app" {
+  for_each = variable.apps
+
+  env = {
+    IMAGE = each.value
+  }
+
+  command = ["app", each.key]
+
+  network {
+    name = resource.network.main.meta.name
+  }
+}
+
+resource "container" "sidecar" {
+  for_each = ["logs", "metrics"]
+
+  command = [each.key, each.value]
+}
+
+resource "container" "proxy" {
+  command = resource.container.app["api"].command
+}
+
+output "app_ids" {
+  value = [for k, v in resource.container.app : v.meta.id]
+}
+
+output "sidecar_count" {
+  value = len(resource.container.sidecar)
+}
+
+module "env" {
+  for_each = variable.apps
+
+  source = "./modules/app"
+
+  variables = {
+    image = each.value
+  }
+}
+
+output "api_image" {
+  value = module.env["api"].output.image
+}
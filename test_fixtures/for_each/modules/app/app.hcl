@@ -0,0 +1,13 @@
+variable "image" {
+  default = ""
+}
+
+resource "container" "app" {
+  env = {
+    IMAGE = variable.image
+  }
+}
+
+output "image" {
+  value = resource.container.app.env.IMAGE
+}
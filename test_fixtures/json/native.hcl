@@ -0,0 +1,7 @@
+resource "container" "native" {
+  command = [local.image]
+
+  resources {
+    cpu = resource.container.consul.resources.cpu
+  }
+}
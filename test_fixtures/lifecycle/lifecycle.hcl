@@ -0,0 +1,40 @@
+variable "cpu" {
+  default = 2000
+}
+
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" "app" {
+  resources {
+    cpu = variable.cpu
+  }
+
+  lifecycle {
+    precondition {
+      condition     = resource.network.main.subnet == "10.0.0.0/16"
+      error_message = "the network must use the subnet 10.0.0.0/16"
+    }
+
+    precondition {
+      condition     = variable.cpu <= 4000
+      error_message = "cpu must be less than or equal to 4000, got ${variable.cpu}"
+    }
+
+    // created_network is set when the container is processed
+    postcondition {
+      condition     = len(self.created_network) == 2
+      error_message = "the container must create two networks"
+    }
+
+    postcondition {
+      condition     = self.resources.cpu >= 1000
+      error_message = "the container must have at least 1000 cpu, got ${self.resources.cpu}"
+    }
+  }
+}
+
+resource "container" "sidecar" {
+  depends_on = ["resource.container.app"]
+}
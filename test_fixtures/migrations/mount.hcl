@@ -0,0 +1,4 @@
+resource "mount" "app" {
+  host_path      = "./app"
+  container_path = "/app"
+}
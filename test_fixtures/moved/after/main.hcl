@@ -0,0 +1,25 @@
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" "frontend" {
+  command = ["nginx"]
+
+  network {
+    name = resource.network.main.meta.name
+  }
+}
+
+moved {
+  from = resource.container.web
+  to   = resource.container.frontend
+}
+
+module "data" {
+  source = "../database"
+}
+
+moved {
+  from = resource.container.db
+  to   = module.data.resource.container.db
+}
@@ -0,0 +1,19 @@
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" "web" {
+  command = ["nginx"]
+
+  network {
+    name = resource.network.main.meta.name
+  }
+}
+
+resource "container" "db" {
+  command = ["postgres"]
+
+  resources {
+    cpu = 1000
+  }
+}
@@ -0,0 +1,7 @@
+resource "container" "db" {
+  command = ["postgres"]
+
+  resources {
+    cpu = 1000
+  }
+}
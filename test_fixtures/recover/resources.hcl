@@ -0,0 +1,12 @@
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
+
+resource "container" {
+}
+
+unknown "container" "web" {
+}
+
+resource "parse_error" "mine" {
+}
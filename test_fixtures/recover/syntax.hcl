@@ -0,0 +1,3 @@
+resource "network" "broken" {
+  subnet = 
+}
@@ -0,0 +1,5 @@
+resource "container" "web" {
+  network {
+    name = resource.network.main.meta.name
+  }
+}
@@ -0,0 +1,10 @@
+resource "container" "web" {
+  network {
+    name       = resource.network.main.meta.name
+    ip_address = "10.0.0.2"
+  }
+
+  resources {
+    cpu = variable.cpu
+  }
+}
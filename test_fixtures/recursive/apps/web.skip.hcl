@@ -0,0 +1,2 @@
+resource "container" "skipped" {
+}
@@ -0,0 +1 @@
+resource "container" {
@@ -0,0 +1,7 @@
+variable "cpu" {
+  default = 512
+}
+
+module "app" {
+  source = "./modules/app"
+}
@@ -0,0 +1,2 @@
+resource "container" "module_app" {
+}
@@ -0,0 +1,3 @@
+resource "network" "main" {
+  subnet = "10.0.0.0/16"
+}
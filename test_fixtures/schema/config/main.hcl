@@ -0,0 +1,54 @@
+variable "age" {
+  default = 42
+}
+
+resource "person" "nic" {
+  name     = "Nic"
+  age      = variable.age
+  admin    = true
+  tags     = ["dev", "ops"]
+  password = "secret"
+
+  labels = {
+    team = "platform"
+  }
+
+  address {
+    city = "London"
+  }
+
+  pet {
+    name = "Rocky"
+    age  = 5
+  }
+
+  pet {
+    name = "Tiger"
+  }
+}
+
+resource "person" "erik" {
+  name = "Erik"
+  age  = resource.person.nic.age + 1
+
+  address {
+    city = resource.person.nic.address.city
+  }
+}
+
+resource "team" "platform" {
+  name    = resource.person.nic.labels.team
+  members = [resource.person.nic.name, resource.person.erik.name]
+
+  lead {
+    name = resource.person.nic.name
+  }
+}
+
+output "first_pet" {
+  value = resource.person.nic.pet[0].name
+}
+
+output "nic_id" {
+  value = resource.person.nic.id
+}
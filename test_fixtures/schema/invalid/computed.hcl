@@ -0,0 +1,4 @@
+resource "person" "nic" {
+  name = "Nic"
+  id   = "abc"
+}
@@ -0,0 +1,3 @@
+resource "team" "platform" {
+  name = "platform"
+}
@@ -0,0 +1,54 @@
+schema "person" {
+  field "name" {
+    type     = "string"
+    required = true
+  }
+
+  field "age" {
+    type = "int"
+  }
+
+  field "admin" {
+    type = "bool"
+  }
+
+  field "tags" {
+    type = "list(string)"
+  }
+
+  field "labels" {
+    type = "map(string)"
+  }
+
+  field "password" {
+    type      = "string"
+    sensitive = true
+  }
+
+  field "address" {
+    type = "block"
+
+    field "city" {
+      type     = "string"
+      required = true
+    }
+  }
+
+  field "pet" {
+    type = "list(block)"
+
+    field "name" {
+      type     = "string"
+      required = true
+    }
+
+    field "age" {
+      type = "int"
+    }
+  }
+
+  field "id" {
+    type     = "string"
+    computed = true
+  }
+}
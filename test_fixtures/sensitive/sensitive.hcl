@@ -0,0 +1,45 @@
+variable "db_password" {
+  default   = "s3cr3t-pa55"
+  sensitive = true
+}
+
+variable "username" {
+  default = "admin"
+}
+
+resource "container" "db" {
+  env = {
+    PASSWORD = variable.db_password
+    USER     = variable.username
+  }
+
+  auth {
+    username = variable.username
+    password = "registry-pa55"
+  }
+}
+
+resource "container" "app" {
+  command = ["connect", resource.container.db.env.PASSWORD]
+
+  env = {
+    USER = resource.container.db.auth.username
+  }
+}
+
+output "connection" {
+  value = "${variable.username}:${resource.container.db.env.PASSWORD}"
+}
+
+output "registry_password" {
+  value = resource.container.db.auth.password
+}
+
+output "username" {
+  value = variable.username
+}
+
+output "token" {
+  value     = "not-a-real-token"
+  sensitive = true
+}
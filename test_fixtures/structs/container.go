@@ -36,6 +36,9 @@ type Container struct {
 	// User block for mapping the user id and group id inside the container
 	RunAs *User `hcl:"run_as,block" json:"run_as,omitempty" mapstructure:"run_as"`
 
+	// Auth block for the credentials used to pull the image
+	Auth *Auth `hcl:"auth,block" json:"auth,omitempty"`
+
 	// output
 	CreatedNetworks    []NetworkAttachment `hcl:"created_network,optional" json:"created_networks,omitempty"`         // Attach to the correct network // only when Image is specified
 	CreatedNetworksMap map[string]Network  `hcl:"created_network_map,optional" json:"created_networks_map,omitempty"` // Attach to the correct network // only when Image is specified
@@ -43,6 +46,12 @@ type Container struct {
 	Output cty.Value `hcl:"output,optional" json:"output,omitempty"`
 }
 
+// Auth defines the credentials for an image registry
+type Auth struct {
+	Username string `hcl:"username" json:"username"`
+	Password string `hcl:"password" json:"password" sensitive:"true"`
+}
+
 type User struct {
 	// Username or UserID of the user to run the container as
 	User string `hcl:"user" json:"user,omitempty" mapstructure:"user"`
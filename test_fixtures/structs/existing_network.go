@@ -0,0 +1,18 @@
+package structs
+
+import "github.com/jumppad-labs/hclconfig/types"
+
+// ExistingNetwork is a data source that looks up an existing Docker network
+type ExistingNetwork struct {
+	types.ResourceBase `hcl:",remain"`
+
+	Name string `hcl:"name" json:"name"`
+
+	// output
+	Subnet string `hcl:"subnet,optional" json:"subnet,omitempty"`
+}
+
+func (n *ExistingNetwork) Process() error {
+	n.Subnet = "10.5.0.0/16"
+	return nil
+}
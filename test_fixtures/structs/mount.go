@@ -0,0 +1,43 @@
+package structs
+
+import (
+	"fmt"
+
+	"github.com/jumppad-labs/hclconfig/types"
+)
+
+// TypeMount is the resource string for a Mount resource
+const TypeMount = "mount"
+
+// MountSchemaVersion is the current version of the schema of the Mount
+// resource
+//
+// 0: source and target
+// 1: source renamed to host_path
+// 2: target renamed to container_path
+const MountSchemaVersion = 2
+
+// Mount mounts a folder on the host into a container
+type Mount struct {
+	types.ResourceBase `hcl:",remain"`
+
+	HostPath      string `hcl:"host_path" json:"host_path"`
+	ContainerPath string `hcl:"container_path" json:"container_path"`
+}
+
+func (m *Mount) Migrate(version int, data map[string]any) (map[string]any, error) {
+	switch version {
+	case 0:
+		data["host_path"] = data["source"]
+		delete(data, "source")
+	case 1:
+		if _, ok := data["target"].(string); !ok {
+			return nil, fmt.Errorf("target must be a string")
+		}
+
+		data["container_path"] = data["target"]
+		delete(data, "target")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,14 @@
+package structs
+
+import "github.com/jumppad-labs/hclconfig/types"
+
+// TypePipeline is the block type for Pipeline blocks
+const TypePipeline = "pipeline"
+
+// Pipeline defines the steps that are run in a container
+type Pipeline struct {
+	types.ResourceBase `hcl:",remain"`
+
+	Container string   `hcl:"container" json:"container"`
+	Steps     []string `hcl:"steps" json:"steps"`
+}
@@ -0,0 +1,22 @@
+package structs
+
+import "github.com/jumppad-labs/hclconfig/types"
+
+// TypeProvider is the block type for Provider blocks
+const TypeProvider = "provider"
+
+// Provider configures the connection to a Docker engine
+type Provider struct {
+	types.ResourceBase `hcl:",remain"`
+
+	Version string `hcl:"version" json:"version"`
+	Host    string `hcl:"host,optional" json:"host,omitempty"`
+}
+
+func (p *Provider) Process() error {
+	if p.Host == "" {
+		p.Host = "unix:///var/run/docker.sock"
+	}
+
+	return nil
+}
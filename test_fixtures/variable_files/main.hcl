@@ -0,0 +1,67 @@
+variable "service" {
+  type = object({
+    name  = string
+    ports = list(number)
+  })
+
+  default = {
+    name  = "default"
+    ports = []
+  }
+}
+
+variable "regions" {
+  default = []
+}
+
+variable "replicas" {
+  type    = number
+  default = 1
+}
+
+variable "debug" {
+  type    = bool
+  default = false
+}
+
+variable "greeting" {
+  default = ""
+}
+
+variable "ports" {
+  type    = list(number)
+  default = []
+}
+
+variable "version" {
+  type    = string
+  default = ""
+}
+
+output "service" {
+  value = variable.service
+}
+
+output "regions" {
+  value = variable.regions
+}
+
+output "replicas" {
+  value = variable.replicas
+}
+
+output "debug" {
+  value = variable.debug
+}
+
+output "greeting" {
+  value = variable.greeting
+}
+
+output "ports" {
+  value = variable.ports
+}
+
+output "version" {
+  value = variable.version
+}
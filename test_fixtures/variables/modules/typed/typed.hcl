@@ -0,0 +1,17 @@
+variable "port" {
+  type    = number
+  default = 80
+}
+
+variable "replicas" {
+  type    = number
+  default = 1
+}
+
+output "port" {
+  value = variable.port
+}
+
+output "replicas" {
+  value = variable.replicas
+}
@@ -0,0 +1,67 @@
+variable "ports" {
+  type    = list(number)
+  default = ["80", 443]
+}
+
+variable "service" {
+  type = object({
+    name = string
+    port = optional(number, 8080)
+  })
+
+  default = {
+    name = "api"
+  }
+}
+
+variable "version" {
+  type    = string
+  default = "1"
+}
+
+variable "replicas" {
+  type    = number
+  default = 1
+
+  validation {
+    condition     = variable.replicas > 0
+    error_message = "replicas must be greater than 0"
+  }
+}
+
+resource "container" "app" {
+  command = [variable.service.name, "${variable.service.port}"]
+
+  env = {
+    PORT = "9090"
+  }
+}
+
+module "typed" {
+  source = "./modules/typed"
+
+  variables = {
+    port     = resource.container.app.env.PORT
+    replicas = "2"
+  }
+}
+
+output "ports" {
+  value = variable.ports
+}
+
+output "service" {
+  value = variable.service
+}
+
+output "version" {
+  value = variable.version
+}
+
+output "replicas" {
+  value = variable.replicas
+}
+
+output "module_port" {
+  value = module.typed.output.port
+}
@@ -17,15 +17,27 @@ func NewTypeNotRegisteredError(t string) *ErrTypeNotRegistered {
 	return &ErrTypeNotRegistered{Type: t}
 }
 
+// Factory defines an optional interface for registered types that are not
+// defined by a Go struct, new instances of the type are created by the
+// registered value rather than from its Go type
+type Factory interface {
+	// NewResource returns a new empty instance of the type
+	NewResource() Resource
+}
+
 type RegisteredTypes map[string]Resource
 
 // CreateResource creates a new instance of a resource from one of the registered types.
 func (r RegisteredTypes) CreateResource(resourceType, resourceName string) (Resource, error) {
 	// check that the type exists
 	if t, ok := r[resourceType]; ok {
-		ptr := reflect.New(reflect.TypeOf(t).Elem())
+		var res Resource
+		if f, ok := t.(Factory); ok {
+			res = f.NewResource()
+		} else {
+			res = reflect.New(reflect.TypeOf(t).Elem()).Interface().(Resource)
+		}
 
-		res := ptr.Interface().(Resource)
 		res.Metadata().Name = resourceName
 		res.Metadata().Type = resourceType
 		res.Metadata().Properties = map[string]any{}
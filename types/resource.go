@@ -2,6 +2,14 @@ package types
 
 var TypeResource = "resource"
 
+// TypeData is the block type for data sources, data sources are read only
+// resources that look up existing infrastructure
+var TypeData = "data"
+
+// RedactedValue replaces sensitive values when a resource is serialized or
+// included in an error message
+const RedactedValue = "(sensitive value)"
+
 // Parsable defines an optional interface that allows a resource to be
 // modified directly after it has been loaded from a file
 //
@@ -40,6 +48,20 @@ type Processable interface {
 	Process() error
 }
 
+// Migratable defines an optional interface that allows a resource to upgrade
+// JSON that was serialized with an older version of its schema.
+//
+// Migratable should be implemented when the schema version of a registered type
+// is increased, i.e. after renaming or restructuring a field.
+type Migratable interface {
+	// Migrate is called by Parser.UnmarshalJSON to upgrade the serialized resource
+	// from the given schema version to the next version. Migrate is called once
+	// for every version between the serialized and the current schema version.
+	//
+	// Returning an error stops the migration and the resource is not loaded.
+	Migrate(version int, data map[string]any) (map[string]any, error)
+}
+
 // Resource is an interface that all
 type Resource interface {
 	// return the resource Metadata
@@ -65,10 +87,31 @@ type Meta struct {
 	// this is an internal property that can not be set with hcl
 	Type string `hcl:"type,optional" json:"type"`
 
+	// Data is true when the resource is a data source that has been defined
+	// using a data block rather than a resource block
+	// this is an internal property that can not be set with hcl
+	Data bool `json:"data,omitempty"`
+
+	// SchemaVersion is the version of the schema of the registered type that
+	// the resource was created with, used to migrate serialized resources
+	// this is an internal property that can not be set with hcl
+	SchemaVersion int `json:"schema_version,omitempty"`
+
 	// Module is the name of the module if a resource has been loaded from a module
 	// this is an internal property that can not be set with hcl
 	Module string `hcl:"module,optional" json:"module,omitempty"`
 
+	// Index is the position of the resource when it is an instance of a resource
+	// that has been expanded with the count meta-argument, nil when count is not set
+	// this is an internal property that can not be set with hcl
+	Index *int `hcl:"index,optional" json:"index,omitempty"`
+
+	// Key is the key of the resource when it is an instance of a resource or
+	// module that has been expanded with the for_each meta-argument, nil when
+	// for_each is not set
+	// this is an internal property that can not be set with hcl
+	Key *string `hcl:"key,optional" json:"key,omitempty"`
+
 	// File is the absolute path of the file where the resource is defined
 	// this is an internal property that can not be set with hcl
 	File string `hcl:"file,optional" json:"file"`
@@ -90,16 +133,21 @@ type Meta struct {
 	// Linked resources which must be set before this config can be processed
 	// this is an internal property that can not be set with hcl
 	Links []string `json:"links,omitempty"`
+
+	// Sensitive contains the paths of the attributes that have been set from a
+	// sensitive value i.e. password or network[0].name
+	// this is an internal property that can not be set with hcl
+	Sensitive []string `json:"sensitive,omitempty"`
 }
 
 // ResourceBase is the embedded type for any config resources
 // it defines common meta data that all resources share
 type ResourceBase struct {
 	// DependsOn is a user configurable list of dependencies for this resource
-	DependsOn []string `hcl:"depends_on,optional" json:"depends_on,omitempty"`
+	DependsOn []string `hcl:"depends_on,optional" json:"depends_on,omitempty" description:"resources that must be processed before this resource"`
 
 	// Enabled determines if a resource is enabled and should be processed
-	Disabled bool `hcl:"disabled,optional" json:"disabled,omitempty"`
+	Disabled bool `hcl:"disabled,optional" json:"disabled,omitempty" description:"disabled resources are not processed"`
 
 	Meta Meta `hcl:"meta,optional" json:"meta,omitempty"`
 }
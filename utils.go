@@ -1,11 +1,9 @@
 package hclconfig
 
 import (
-	"bufio"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
-	"os"
 	"sort"
 
 	"github.com/jumppad-labs/hclconfig/types"
@@ -27,35 +25,7 @@ func ParseVars(value map[string]cty.Value) map[string]any {
 
 // ReadFileLocation reads a file between the given locations
 func ReadFileLocation(filename string, startLine, startCol, endLine, endCol int) (string, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return "", fmt.Errorf("file not found: %s", err)
-	}
-
-	fs := bufio.NewScanner(f)
-
-	cl := 0
-	output := ""
-
-	for fs.Scan() {
-		cl++
-		if cl >= startLine && cl <= endLine {
-			switch cl {
-			case startLine:
-				if startLine == endLine {
-					output += fs.Text()[startCol-1 : endCol-1]
-				} else {
-					output += fmt.Sprintf("%s%s", fs.Text()[startCol-1:], LineEnding)
-				}
-			case endLine:
-				output += fs.Text()[:endCol-1]
-			default:
-				output += fmt.Sprintf("%s%s", fs.Text(), LineEnding)
-			}
-		}
-	}
-
-	return output, nil
+	return readFileLocation(osFS{}, filename, startLine, startCol, endLine, endCol)
 }
 
 // HashString creates an MD5 hash of the given string
@@ -70,6 +40,11 @@ func HashString(in string) string {
 // Rock the cast var
 // Rock the cast var
 func castVar(v cty.Value) any {
+	// null primitives such as an unset meta.index can not be converted
+	if v.Type().IsPrimitiveType() && v.IsNull() {
+		return nil
+	}
+
 	if v.Type() == cty.String {
 		return v.AsString()
 	} else if v.Type() == cty.Bool {
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestReadResourceFromFileAtLocation(t *testing.T) {
@@ -28,6 +29,16 @@ func TestCalculatesHashFromString(t *testing.T) {
 	require.Equal(t, h, "b10a8db164e0754105b7a99be72e3fe5")
 }
 
+func TestParseVarsConvertsNullValues(t *testing.T) {
+	vars := ParseVars(map[string]cty.Value{
+		"index": cty.NullVal(cty.Number),
+		"name":  cty.StringVal("mine"),
+	})
+
+	require.Nil(t, vars["index"])
+	require.Equal(t, "mine", vars["name"])
+}
+
 var singleLine = `resource "container" "consul"`
 
 var container = `resource "container" "consul" {
@@ -0,0 +1,393 @@
+package hclconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"gopkg.in/yaml.v3"
+)
+
+// VariablesDecoder decodes the contents of a variables file into the values of
+// the variables it sets. The context contains the functions available to the
+// configuration. Errors should be returned as a *errors.ParserError containing
+// the line and column of the problem.
+type VariablesDecoder func(filename string, src []byte, ctx *hcl.EvalContext) (map[string]cty.Value, error)
+
+// defaultVariablesDecoders returns the decoders for the variables file formats
+// that are supported by default, keyed by the file extension
+func defaultVariablesDecoders() map[string]VariablesDecoder {
+	return map[string]VariablesDecoder{
+		".vars": decodeHCLVariables,
+		".json": decodeJSONVariables,
+		".yaml": decodeYAMLVariables,
+		".yml":  decodeYAMLVariables,
+		".env":  decodeDotEnvVariables,
+	}
+}
+
+// variablesDecoder returns the decoder for the extension of the file, files with
+// an unknown extension are decoded as HCL
+func (p *Parser) variablesDecoder(file string) VariablesDecoder {
+	if d, ok := p.variablesDecoders[path.Ext(file)]; ok {
+		return d
+	}
+
+	return decodeHCLVariables
+}
+
+// isVariablesFile returns true when a file found in a parsed directory contains
+// variables, files with the extension .vars or with .vars followed by the
+// extension of a registered decoder i.e. prod.vars.json
+func (p *Parser) isVariablesFile(file string) bool {
+	ext := path.Ext(file)
+	if ext == ".vars" {
+		return true
+	}
+
+	if _, ok := p.variablesDecoders[ext]; !ok {
+		return false
+	}
+
+	return path.Ext(strings.TrimSuffix(file, ext)) == ".vars"
+}
+
+// variablesFileError creates a ParserError at the given location in a variables file
+func variablesFileError(filename string, line, column int, message string) *errors.ParserError {
+	de := &errors.ParserError{}
+	de.Filename = filename
+	de.Line = line
+	de.Column = column
+	de.Level = errors.ParserErrorLevelError
+	de.Message = fmt.Sprintf("unable to parse file: %s", message)
+
+	return de
+}
+
+// decodeHCLVariables decodes a file containing HCL attributes, the expressions
+// can use any of the functions available to the configuration
+func decodeHCLVariables(filename string, src []byte, ctx *hcl.EvalContext) (map[string]cty.Value, error) {
+	f, diag := hclparse.NewParser().ParseHCL(src, filename)
+	if diag.HasErrors() {
+		line, column := 0, 0
+		if diag[0].Subject != nil {
+			line = diag[0].Subject.Start.Line
+			column = diag[0].Subject.Start.Column
+		}
+
+		return nil, variablesFileError(filename, line, column, diag[0].Detail)
+	}
+
+	values := map[string]cty.Value{}
+
+	attrs, _ := f.Body.JustAttributes()
+	for name, attr := range attrs {
+		val, _ := attr.Expr.Value(ctx)
+		values[name] = val
+	}
+
+	return values, nil
+}
+
+// decodeJSONVariables decodes a file containing a JSON object, the type of the
+// values is inferred from the JSON
+func decodeJSONVariables(filename string, src []byte, ctx *hcl.EvalContext) (map[string]cty.Value, error) {
+	// validate the JSON first to get the location of any syntax errors
+	var raw any
+	if err := json.Unmarshal(src, &raw); err != nil {
+		offset := int64(0)
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			offset = e.Offset
+		case *json.UnmarshalTypeError:
+			offset = e.Offset
+		}
+
+		line, column := offsetLocation(src, offset)
+
+		return nil, variablesFileError(filename, line, column, err.Error())
+	}
+
+	if _, ok := raw.(map[string]any); !ok {
+		line, column := offsetLocation(src, int64(len(src)-len(bytes.TrimLeft(src, " \t\r\n"))+1))
+		return nil, variablesFileError(filename, line, column, "variables file must contain a JSON object")
+	}
+
+	ty, err := ctyjson.ImpliedType(src)
+	if err != nil {
+		return nil, variablesFileError(filename, 1, 1, err.Error())
+	}
+
+	val, err := ctyjson.Unmarshal(src, ty)
+	if err != nil {
+		return nil, variablesFileError(filename, 1, 1, err.Error())
+	}
+
+	values := map[string]cty.Value{}
+	for k, v := range val.AsValueMap() {
+		values[k] = v
+	}
+
+	return values, nil
+}
+
+// offsetLocation returns the line and column of the byte offset in src
+func offsetLocation(src []byte, offset int64) (int, int) {
+	if offset > int64(len(src)) {
+		offset = int64(len(src))
+	}
+
+	line, column := 1, 1
+	for _, b := range src[:max(offset-1, 0)] {
+		if b == '\n' {
+			line++
+			column = 1
+			continue
+		}
+
+		column++
+	}
+
+	return line, column
+}
+
+var yamlLineRegex = regexp.MustCompile(`line (\d+)`)
+
+// decodeYAMLVariables decodes a file containing a YAML mapping, scalars are
+// converted using their resolved YAML tags
+func decodeYAMLVariables(filename string, src []byte, ctx *hcl.EvalContext) (map[string]cty.Value, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(src, doc); err != nil {
+		// yaml only reports the line of syntax errors
+		line := 1
+		if m := yamlLineRegex.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+
+		return nil, variablesFileError(filename, line, 1, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	values := map[string]cty.Value{}
+
+	// an empty file does not contain a document
+	if len(doc.Content) == 0 {
+		return values, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, variablesFileError(filename, root.Line, root.Column, "variables file must contain a YAML mapping")
+	}
+
+	val, err := yamlNodeToValue(filename, root)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range val.AsValueMap() {
+		values[k] = v
+	}
+
+	return values, nil
+}
+
+// yamlNodeToValue converts a YAML node to a cty value, mappings are converted
+// to objects and sequences to tuples
+func yamlNodeToValue(filename string, n *yaml.Node) (cty.Value, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlNodeToValue(filename, n.Alias)
+
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+
+		vals := []cty.Value{}
+		for _, c := range n.Content {
+			v, err := yamlNodeToValue(filename, c)
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			vals = append(vals, v)
+		}
+
+		return cty.TupleVal(vals), nil
+
+	case yaml.MappingNode:
+		vals := map[string]cty.Value{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.Kind != yaml.ScalarNode {
+				return cty.NilVal, variablesFileError(filename, k.Line, k.Column, "keys must be strings")
+			}
+
+			v, err := yamlNodeToValue(filename, n.Content[i+1])
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			vals[k.Value] = v
+		}
+
+		return cty.ObjectVal(vals), nil
+
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+			return cty.NullVal(cty.DynamicPseudoType), nil
+
+		case "!!bool":
+			var b bool
+			if err := n.Decode(&b); err != nil {
+				return cty.NilVal, variablesFileError(filename, n.Line, n.Column, err.Error())
+			}
+
+			return cty.BoolVal(b), nil
+
+		case "!!int", "!!float":
+			var f any
+			if err := n.Decode(&f); err != nil {
+				return cty.NilVal, variablesFileError(filename, n.Line, n.Column, err.Error())
+			}
+
+			switch v := f.(type) {
+			case int:
+				return cty.NumberIntVal(int64(v)), nil
+			case int64:
+				return cty.NumberIntVal(v), nil
+			case uint64:
+				return cty.NumberUIntVal(v), nil
+			case float64:
+				if math.IsInf(v, 0) || math.IsNaN(v) {
+					return cty.NilVal, variablesFileError(filename, n.Line, n.Column, fmt.Sprintf("%s is not a supported number", n.Value))
+				}
+
+				return cty.NumberVal(new(big.Float).SetFloat64(v)), nil
+			}
+		}
+
+		return cty.StringVal(n.Value), nil
+	}
+
+	return cty.NilVal, variablesFileError(filename, n.Line, n.Column, "unsupported YAML value")
+}
+
+var dotEnvKeyRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// markUntyped is applied to values that have been read as strings, like
+// environment variables they are parsed using the declared type of the variable
+const markUntyped = valueMark("untyped")
+
+// decodeDotEnvVariables decodes a file containing KEY=value lines, the values
+// are strings that are parsed in the same way as environment variables i.e.
+// ports=[80, 443]. Values can be quoted with double quotes which support escape
+// sequences or single quotes which do not, lines starting with # are comments.
+func decodeDotEnvVariables(filename string, src []byte, ctx *hcl.EvalContext) (map[string]cty.Value, error) {
+	values := map[string]cty.Value{}
+
+	for i, l := range strings.Split(string(src), "\n") {
+		line := i + 1
+		l = strings.TrimSuffix(l, "\r")
+
+		trimmed := strings.TrimSpace(l)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// column of the first character of the statement
+		column := len(l) - len(strings.TrimLeft(l, " \t")) + 1
+
+		stmt := strings.TrimPrefix(trimmed, "export ")
+		column += len(trimmed) - len(stmt)
+
+		key, value, ok := strings.Cut(stmt, "=")
+		if !ok {
+			return nil, variablesFileError(filename, line, column, fmt.Sprintf(`expected KEY=value, got "%s"`, stmt))
+		}
+
+		key = strings.TrimSpace(key)
+		if !dotEnvKeyRegex.MatchString(key) {
+			return nil, variablesFileError(filename, line, column, fmt.Sprintf(`invalid variable name "%s"`, key))
+		}
+
+		// column of the first character of the value
+		column += len(stmt) - len(value)
+		column += len(value) - len(strings.TrimLeft(value, " \t"))
+		value = strings.TrimLeft(value, " \t")
+
+		v, err := dotEnvValue(value)
+		if err != nil {
+			return nil, variablesFileError(filename, line, column, err.Error())
+		}
+
+		values[key] = cty.StringVal(v).Mark(markUntyped)
+	}
+
+	return values, nil
+}
+
+// dotEnvValue returns the value of a dotenv statement removing any quotes and
+// trailing comments
+func dotEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	quote := value[0]
+	if quote != '"' && quote != '\'' {
+		// unquoted values end at a comment
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+
+		return strings.TrimSpace(value), nil
+	}
+
+	v := strings.Builder{}
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+
+		if c == quote {
+			rest := strings.TrimSpace(value[i+1:])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf(`unexpected characters "%s" after quoted value`, rest)
+			}
+
+			return v.String(), nil
+		}
+
+		// escape sequences are only supported in double quoted values
+		if c == '\\' && quote == '"' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n':
+				v.WriteByte('\n')
+			case 't':
+				v.WriteByte('\t')
+			case 'r':
+				v.WriteByte('\r')
+			default:
+				v.WriteByte(value[i])
+			}
+
+			continue
+		}
+
+		v.WriteByte(c)
+	}
+
+	return "", fmt.Errorf("unterminated quoted value")
+}
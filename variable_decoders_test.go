@@ -0,0 +1,166 @@
+package hclconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParseLoadsVariablesFromJSONYAMLAndDotEnvFiles(t *testing.T) {
+	absoluteFolderPath, err := filepath.Abs("./test_fixtures/variable_files")
+	require.NoError(t, err)
+
+	o := DefaultOptions()
+	o.VariablesFiles = []string{filepath.Join(absoluteFolderPath, "ci.env")}
+
+	p := setupParser(t, o)
+
+	c, err := p.ParseDirectory(absoluteFolderPath)
+	require.NoError(t, err)
+
+	outputs := map[string]any{}
+	for _, n := range []string{"service", "regions", "replicas", "debug", "greeting", "ports", "version"} {
+		r, err := c.FindResource("output." + n)
+		require.NoError(t, err)
+
+		outputs[n] = r.(*resources.Output).Value
+	}
+
+	require.Equal(t, "api", outputs["service"].(map[string]any)["name"])
+	require.Len(t, outputs["service"].(map[string]any)["ports"], 2)
+	require.Len(t, outputs["regions"], 2)
+	require.Equal(t, "eu-west-1", outputs["regions"].([]any)[0].(map[string]any)["name"])
+	require.EqualValues(t, 3, outputs["replicas"])
+	require.Equal(t, true, outputs["debug"])
+	require.Equal(t, "hello\nworld", outputs["greeting"])
+	require.Equal(t, []any{float64(80), float64(443)}, outputs["ports"])
+	require.Equal(t, "1.10", outputs["version"])
+
+	r, err := c.FindResource("variable.regions")
+	require.NoError(t, err)
+	require.Equal(t, "file:"+filepath.Join(absoluteFolderPath, "regions.vars.yaml"), r.(*resources.Variable).Source.String())
+}
+
+func TestParseUsesRegisteredVariablesDecoder(t *testing.T) {
+	p := setupParser(t)
+	p.RegisterVariablesDecoder(".custom", func(filename string, src []byte, ctx *hcl.EvalContext) (map[string]cty.Value, error) {
+		return map[string]cty.Value{"name": cty.StringVal(string(src))}, nil
+	})
+
+	c, err := p.ParseSources(map[string][]byte{
+		"main.hcl": []byte(`
+variable "name" {
+  default = "default"
+}
+`),
+		"prod.vars.custom": []byte(`custom`),
+	})
+	require.NoError(t, err)
+
+	r, err := c.FindResource("variable.name")
+	require.NoError(t, err)
+	require.Equal(t, "file:prod.vars.custom", r.(*resources.Variable).Source.String())
+}
+
+func TestDecodeJSONVariablesReturnsErrorLocation(t *testing.T) {
+	_, err := decodeJSONVariables("vars.json", []byte("{\n  \"name\": \"api\",\n  \"ports\": [80 443]\n}"), nil)
+	require.Error(t, err)
+
+	pe := err.(*errors.ParserError)
+	require.Equal(t, "vars.json", pe.Filename)
+	require.Equal(t, 3, pe.Line)
+	require.Equal(t, 16, pe.Column)
+}
+
+func TestDecodeJSONVariablesReturnsErrorWhenNotAnObject(t *testing.T) {
+	_, err := decodeJSONVariables("vars.json", []byte("\n  [1, 2]"), nil)
+	require.Error(t, err)
+
+	pe := err.(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Equal(t, 3, pe.Column)
+	require.Contains(t, pe.Message, "must contain a JSON object")
+}
+
+func TestDecodeYAMLVariablesConvertsTypes(t *testing.T) {
+	vals, err := decodeYAMLVariables("vars.yaml", []byte(`
+name: api
+replicas: 3
+ratio: 0.5
+enabled: true
+empty: ~
+version: "1"
+ports: [80, 443]
+defaults: &defaults
+  region: eu-west-1
+prod: *defaults
+`), nil)
+	require.NoError(t, err)
+
+	require.Equal(t, cty.StringVal("api"), vals["name"])
+	require.True(t, vals["replicas"].RawEquals(cty.NumberIntVal(3)))
+	require.True(t, vals["ratio"].Equals(cty.NumberFloatVal(0.5)).True())
+	require.Equal(t, cty.True, vals["enabled"])
+	require.True(t, vals["empty"].IsNull())
+	require.Equal(t, cty.StringVal("1"), vals["version"])
+	require.Equal(t, 2, vals["ports"].LengthInt())
+	require.Equal(t, cty.StringVal("eu-west-1"), vals["prod"].GetAttr("region"))
+}
+
+func TestDecodeYAMLVariablesReturnsErrorLocation(t *testing.T) {
+	_, err := decodeYAMLVariables("vars.yaml", []byte("name: api\n\tport: 80\n"), nil)
+	require.Error(t, err)
+
+	pe := err.(*errors.ParserError)
+	require.Equal(t, "vars.yaml", pe.Filename)
+	require.Equal(t, 2, pe.Line)
+	require.Contains(t, pe.Message, "tab character")
+
+	_, err = decodeYAMLVariables("vars.yaml", []byte("# list\n  - 80\n"), nil)
+	require.Error(t, err)
+
+	pe = err.(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Equal(t, 3, pe.Column)
+}
+
+func TestDecodeDotEnvVariables(t *testing.T) {
+	vals, err := decodeDotEnvVariables("ci.env", []byte(`
+# comment
+export NAME=api
+PORT = 80 # comment
+DOUBLE="a \"quoted\"\tvalue"
+SINGLE='a \n literal'
+EMPTY=
+`), nil)
+	require.NoError(t, err)
+
+	// values are parsed using the type of the variable when they are set
+	require.Equal(t, cty.StringVal("api").Mark(markUntyped), vals["NAME"])
+	require.Equal(t, cty.StringVal("80").Mark(markUntyped), vals["PORT"])
+	require.Equal(t, cty.StringVal("a \"quoted\"\tvalue").Mark(markUntyped), vals["DOUBLE"])
+	require.Equal(t, cty.StringVal(`a \n literal`).Mark(markUntyped), vals["SINGLE"])
+	require.Equal(t, cty.StringVal("").Mark(markUntyped), vals["EMPTY"])
+}
+
+func TestDecodeDotEnvVariablesReturnsErrorLocation(t *testing.T) {
+	_, err := decodeDotEnvVariables("ci.env", []byte("NAME=api\n  PORT\n"), nil)
+	require.Error(t, err)
+
+	pe := err.(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Equal(t, 3, pe.Column)
+
+	_, err = decodeDotEnvVariables("ci.env", []byte("NAME=api\nIMAGE = \"nginx\n"), nil)
+	require.Error(t, err)
+
+	pe = err.(*errors.ParserError)
+	require.Equal(t, 2, pe.Line)
+	require.Equal(t, 9, pe.Column)
+	require.Contains(t, pe.Message, "unterminated quoted value")
+}
@@ -0,0 +1,352 @@
+package hclconfig
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/jumppad-labs/hclconfig/errors"
+	"github.com/jumppad-labs/hclconfig/resources"
+	"github.com/jumppad-labs/hclconfig/types"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// variableType returns the type constraint declared by the variable and the
+// defaults for any optional attributes, variables that do not declare a type
+// accept any value
+func variableType(v *resources.Variable) (cty.Type, *typeexpr.Defaults, hcl.Diagnostics) {
+	if v.Type == nil {
+		return cty.DynamicPseudoType, nil, nil
+	}
+
+	// a missing type attribute is decoded as an expression returning null
+	if val, diags := v.Type.Value(nil); !diags.HasErrors() && val.IsNull() {
+		return cty.DynamicPseudoType, nil, nil
+	}
+
+	return typeexpr.TypeConstraintWithDefaults(v.Type)
+}
+
+// convertVariableValue converts the value to the type declared by the variable,
+// an error is returned at the location of the variable when the type is invalid
+// or the value can not be converted. The value is marked when the variable is
+// sensitive or the value has been set from a sensitive value.
+func convertVariableValue(v *resources.Variable, val cty.Value) (cty.Value, *errors.ParserError) {
+	val, marks := val.UnmarkDeep()
+	if v.Sensitive {
+		marks = cty.NewValueMarks(markSensitive, marks)
+	}
+
+	ty, defaults, diags := variableType(v)
+	if diags.HasErrors() {
+		return val.WithMarks(marks), createParserError(v, fmt.Sprintf(`invalid type for variable "%s": %s`, v.Meta.Name, diags.Error()))
+	}
+
+	if defaults != nil {
+		val = defaults.Apply(val)
+	}
+
+	cv, err := convert.Convert(val, ty)
+	if err != nil {
+		msg := fmt.Sprintf(`invalid value for variable "%s", expected %s: %s`, v.Meta.Name, typeexpr.TypeString(ty), err)
+		if v.Source != nil {
+			msg = fmt.Sprintf("%s, value set by %s", msg, v.Source)
+		}
+
+		return val.WithMarks(marks), createParserError(v, msg)
+	}
+
+	return cv.WithMarks(marks), nil
+}
+
+// convertVariables converts the values of the variables declared in the config to
+// their declared type, the value is either the default or has been set from a variables
+// file, the environment, the parser options or the inputs of a module
+func convertVariables(ctx *hcl.EvalContext, c *Config) []error {
+	vars, err := c.FindResourcesByType(resources.TypeVariable)
+	if err != nil {
+		return nil
+	}
+
+	errs := []error{}
+	for _, r := range vars {
+		v := r.(*resources.Variable)
+
+		// variables from child modules are converted when the module is parsed
+		if v.Meta.Module != "" {
+			continue
+		}
+
+		val, ok := getContextVariableFromPath(ctx, "variable."+v.Meta.Name)
+		if !ok {
+			continue
+		}
+
+		cv, perr := convertVariableValue(v, val)
+		if perr != nil {
+			errs = append(errs, perr)
+			continue
+		}
+
+		if cv.ContainsMarked() {
+			c.addSensitiveValues(cv)
+		}
+
+		setContextVariable(ctx, v.Meta.Name, cv)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// variableFromString converts the string value of a variable override. The value
+// is kept as a string when the variable is declared as a string, otherwise it is
+// parsed as an HCL literal expression i.e. [80, 443] or { env = "prod" } and is
+// converted to the declared type when the variables are converted. The type of the
+// value is guessed for variables that do not declare a type.
+func variableFromString(c *Config, name, value string, source *resources.VariableSource) (cty.Value, error) {
+	r, err := c.FindResource("variable." + name)
+	if err != nil {
+		return valueFromString(value), nil
+	}
+
+	v := r.(*resources.Variable)
+
+	ty, _, diags := variableType(v)
+	if diags.HasErrors() || ty == cty.DynamicPseudoType {
+		// invalid types are reported when the variables are converted
+		return valueFromString(value), nil
+	}
+
+	if ty.Equals(cty.String) {
+		return cty.StringVal(value), nil
+	}
+
+	val, diags := literalFromString(value, source.String())
+	if diags.HasErrors() {
+		return cty.NilVal, createParserError(v, fmt.Sprintf(`invalid value for variable "%s", expected %s: the value set by %s is not a valid HCL literal: %s; %s`, name, typeexpr.TypeString(ty), source, diags[0].Summary, diags[0].Detail))
+	}
+
+	return val, nil
+}
+
+// valueFromString parses the string as an HCL literal expression, when the string
+// is not a valid literal the type of the value is guessed. Numbers that would not
+// be written the same way i.e. 1.10 are kept as strings so that values like
+// versions are not changed.
+func valueFromString(v string) cty.Value {
+	if val, diags := literalFromString(v, "value"); !diags.HasErrors() {
+		switch {
+		case val.Type() == cty.Number && !val.IsNull():
+			if val.AsBigFloat().Text('f', -1) == v {
+				return val
+			}
+		case val.Type() != cty.String:
+			return val
+		}
+	}
+
+	if val, err := strconv.ParseBool(v); err == nil {
+		return cty.BoolVal(val)
+	}
+
+	// otherwise return a string
+	return cty.StringVal(v)
+}
+
+// literalFromString parses the string as an HCL expression that does not reference
+// variables or call functions
+func literalFromString(v, filename string) (cty.Value, hcl.Diagnostics) {
+	expr, diags := hclsyntax.ParseExpression([]byte(v), filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	return expr.Value(nil)
+}
+
+// defaultVariablePrecedence is the order the sources of variable values are
+// applied when the precedence is not set in the options
+var defaultVariablePrecedence = []string{
+	resources.VariableSourceFile,
+	resources.VariableSourceEnv,
+	resources.VariableSourceOption,
+}
+
+// variablePrecedence returns the order that the sources of variable values are applied
+func variablePrecedence(precedence []string) []string {
+	if len(precedence) == 0 {
+		return defaultVariablePrecedence
+	}
+
+	return precedence
+}
+
+// validateVariablePrecedence checks that the precedence only contains the sources
+// that can be ordered and that each source is only listed once
+func validateVariablePrecedence(precedence []string) error {
+	seen := map[string]bool{}
+	for _, s := range precedence {
+		if !slices.Contains(defaultVariablePrecedence, s) {
+			return fmt.Errorf(`invalid variable precedence, unknown source "%s", valid sources are %s`, s, strings.Join(defaultVariablePrecedence, ", "))
+		}
+
+		if seen[s] {
+			return fmt.Errorf(`invalid variable precedence, source "%s" is listed more than once`, s)
+		}
+
+		seen[s] = true
+	}
+
+	return nil
+}
+
+// setVariableValue sets the value of a variable in the context and records the
+// source of the value on the variable when it has been declared in the config
+func setVariableValue(ctx *hcl.EvalContext, c *Config, name string, val cty.Value, source *resources.VariableSource) {
+	setContextVariable(ctx, name, val)
+
+	if r, err := c.FindResource("variable." + name); err == nil {
+		r.(*resources.Variable).Source = source
+	}
+}
+
+// isRequired returns true when the variable does not have a default value
+func isRequired(v *resources.Variable) bool {
+	_, ok := v.Default.(*hcl.Attribute)
+	return !ok
+}
+
+// missingVariables returns the names of the required variables that have not been
+// set in the context
+func missingVariables(ctx *hcl.EvalContext, vars []*resources.Variable) []string {
+	missing := []string{}
+	for _, v := range vars {
+		if !isRequired(v) {
+			continue
+		}
+
+		if _, ok := getContextVariableFromPath(ctx, "variable."+v.Meta.Name); !ok {
+			missing = append(missing, fmt.Sprintf(`"%s"`, v.Meta.Name))
+		}
+	}
+
+	sort.Strings(missing)
+
+	return missing
+}
+
+// checkRequiredVariables returns a single error listing all the required variables
+// declared in the given module that have not been set, the error is reported at
+// the location of the first missing variable
+func checkRequiredVariables(ctx *hcl.EvalContext, c *Config, module string) error {
+	found, err := c.FindResourcesByType(resources.TypeVariable)
+	if err != nil {
+		return nil
+	}
+
+	vars := []*resources.Variable{}
+	for _, r := range found {
+		if r.Metadata().Module == module {
+			vars = append(vars, r.(*resources.Variable))
+		}
+	}
+
+	missing := missingVariables(ctx, vars)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// report the error at the first variable that has not been set
+	for _, v := range vars {
+		if fmt.Sprintf(`"%s"`, v.Meta.Name) == missing[0] {
+			return createParserError(v, fmt.Sprintf("required variables have not been set: %s", strings.Join(missing, ", ")))
+		}
+	}
+
+	return nil
+}
+
+// validateVariables evaluates the validation rules for the variables declared in the
+// given module, every failing rule is returned as an error
+func validateVariables(ctx *hcl.EvalContext, c *Config, module string) []error {
+	vars, err := c.FindResourcesByType(resources.TypeVariable)
+	if err != nil {
+		return nil
+	}
+
+	errs := []error{}
+	for _, r := range vars {
+		if r.Metadata().Module != module {
+			continue
+		}
+
+		// required variables that have not been set are reported separately
+		if _, ok := getContextVariableFromPath(ctx, "variable."+r.Metadata().Name); !ok {
+			continue
+		}
+
+		errs = append(errs, validateVariable(ctx, r.(*resources.Variable))...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateVariable evaluates the validation rules for the variable using the given
+// context, conditions that can not yet be determined are ignored
+func validateVariable(ctx *hcl.EvalContext, v *resources.Variable) []error {
+	errs := []error{}
+
+	for _, rule := range v.Validations {
+		result, diags := rule.Condition.Value(ctx)
+		if diags.HasErrors() {
+			errs = append(errs, createParserError(v, fmt.Sprintf(`unable to evaluate validation condition for variable "%s": %s`, v.Meta.Name, diags.Error())))
+			continue
+		}
+
+		// the result of a condition using a sensitive value is also sensitive
+		result, _ = result.UnmarkDeep()
+		if !result.IsKnown() {
+			continue
+		}
+
+		result, err := convert.Convert(result, cty.Bool)
+		if err != nil || result.IsNull() {
+			errs = append(errs, createParserError(v, fmt.Sprintf(`validation condition for variable "%s" must return a bool`, v.Meta.Name)))
+			continue
+		}
+
+		if result.True() {
+			continue
+		}
+
+		msg, diags := rule.ErrorMessage.Value(ctx)
+		if diags.HasErrors() || !msg.IsWhollyKnown() || msg.IsNull() || !msg.Type().Equals(cty.String) {
+			errs = append(errs, createParserError(v, fmt.Sprintf(`invalid value for variable "%s", unable to evaluate error_message`, v.Meta.Name)))
+			continue
+		}
+
+		// the error message must not expose a sensitive value
+		if msg.ContainsMarked() {
+			errs = append(errs, createParserError(v, fmt.Sprintf(`invalid value for variable "%s": %s`, v.Meta.Name, types.RedactedValue)))
+			continue
+		}
+
+		errs = append(errs, createParserError(v, fmt.Sprintf(`invalid value for variable "%s": %s`, v.Meta.Name, msg.AsString())))
+	}
+
+	return errs
+}